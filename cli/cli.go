@@ -30,6 +30,17 @@ func main() {
 
 	app.EnableBashCompletion = true
 
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "repair",
+			Usage: "Repair networks with bad integrity as they load, instead of refusing them, and log what was fixed",
+		},
+	}
+	app.Before = func(c *cli.Context) error {
+		cmd.RepairOnLoad = c.GlobalBool("repair")
+		return nil
+	}
+
 	app.Commands = []cli.Command{
 		{
 			Name:        "train",
@@ -65,6 +76,30 @@ func main() {
 					Name:  "iterations, i",
 					Usage: "Optionally specify the number of times to train on the dataset.",
 				},
+				cli.StringFlag{
+					Name:  "laws, l",
+					Usage: "Optional JSON or YAML laws profile to apply to the network",
+				},
+				cli.StringSliceFlag{
+					Name:  "law",
+					Usage: "Override a single law, like --law SynapseLearnRate=4 (repeatable)",
+				},
+				cli.Int64Flag{
+					Name:  "seed",
+					Usage: "Optionally seed the random number generators so training is repeatable; the seed is saved with the network",
+				},
+				cli.IntFlag{
+					Name:  "threads, t",
+					Usage: "Optional number of local training threads, defaults to the vocab's setting (number of CPUs). Use 1 with --seed for identical output files.",
+				},
+				cli.IntFlag{
+					Name:  "sleep-every",
+					Usage: "Optionally run a sleep phase every so many merges, replaying the vocab outputs and downscaling the other synapses. It is saved with the vocab.",
+				},
+				cli.StringFlag{
+					Name:  "topology",
+					Usage: "How to grow the network when creating it: " + strings.Join(potential.Topologies, ", ") + ". Defaults to random.",
+				},
 			},
 			Before: func(c *cli.Context) error {
 				// validations
@@ -94,15 +129,21 @@ func main() {
 				if iterations == 0 {
 					iterations = 1
 				}
+				lawsProfile := c.String("laws")
+				lawOverrides := c.StringSlice("law")
+				seed := c.Int64("seed")
+				useSeed := c.IsSet("seed")
+				threads := c.Int("threads")
+				sleepEvery := c.Int("sleep-every")
 
 				// run it
 
 				if iterations == 1 {
-					return cmd.Train(networkInputFile, networkSaveFile, vocabSaveFile, testDataFile, doProfile, initialNetworkNeurons)
+					return cmd.Train(networkInputFile, networkSaveFile, vocabSaveFile, testDataFile, doProfile, initialNetworkNeurons, lawsProfile, lawOverrides, seed, useSeed, threads, sleepEvery, c.String("topology"))
 				}
 				for i := 0; i < iterations; i++ {
 					log.Println("------ Start Iteration", i+1, "------")
-					err = cmd.Train(networkInputFile, networkSaveFile, vocabSaveFile, testDataFile, doProfile, initialNetworkNeurons)
+					err = cmd.Train(networkInputFile, networkSaveFile, vocabSaveFile, testDataFile, doProfile, initialNetworkNeurons, lawsProfile, lawOverrides, seed, useSeed, threads, sleepEvery, c.String("topology"))
 					log.Println("------ End Iteration", i+1, "------")
 					if err != nil {
 						log.Println("Failed on iteration", i+1)
@@ -113,6 +154,70 @@ func main() {
 				return err
 			},
 		},
+		{
+			Name:        "init",
+			Usage:       "Create a new network with a structured topology, without training it",
+			Description: "Prints the degree statistics of the network. Train it afterwards with train -n.",
+			ArgsUsage:   "[network file]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "topology",
+					Usage: "How to grow the network: " + strings.Join(potential.Topologies, ", ") + ". Defaults to random.",
+				},
+				cli.IntFlag{
+					Name:  "size, s",
+					Usage: "Number of cells, defaults to 200",
+				},
+				cli.IntFlag{
+					Name:  "degree, d",
+					Usage: "Optional average number of synapses each cell sends, defaults to the IdealCellSynapseBalance law",
+				},
+				cli.Float64Flag{
+					Name:  "rewire",
+					Usage: "Optional chance a small-world synapse goes to a random cell instead of a neighbor, defaults to 0.1",
+				},
+				cli.IntFlag{
+					Name:  "radius",
+					Usage: "Optional number of cells away a lattice cell connects to, defaults to 1",
+				},
+				cli.IntFlag{
+					Name:  "layers",
+					Usage: "Optional number of layers in a layered network, defaults to 4",
+				},
+				cli.StringFlag{
+					Name:  "laws, l",
+					Usage: "Optional JSON or YAML laws profile to apply to the network",
+				},
+				cli.StringSliceFlag{
+					Name:  "law",
+					Usage: "Override a single law, like --law SynapseLearnRate=4 (repeatable)",
+				},
+				cli.Int64Flag{
+					Name:  "seed",
+					Usage: "Optionally seed the random number generator so the network is repeatable; the seed is saved with the network",
+				},
+			},
+			Before: func(c *cli.Context) error {
+				if c.Args().First() == "" {
+					return errors.New("Missing network filename")
+				}
+				return nil
+			},
+			Action: func(c *cli.Context) (err error) {
+				size := c.Int("size")
+				if size == 0 {
+					size = 200
+				}
+				opts := potential.TopologyOptions{
+					Cells:  size,
+					Degree: c.Int("degree"),
+					Rewire: c.Float64("rewire"),
+					Radius: c.Int("radius"),
+					Layers: c.Int("layers"),
+				}
+				return cmd.Init(c.Args().First(), c.String("topology"), opts, c.String("laws"), c.StringSlice("law"), c.Int64("seed"), c.IsSet("seed"))
+			},
+		},
 		{
 			Name:      "sample",
 			Usage:     "Activate a network to produce a sample (prediction)",
@@ -134,6 +239,18 @@ func main() {
 					Name:  "length, l",
 					Usage: "Optional length of response to wait for, defaults to 10",
 				},
+				cli.Int64Flag{
+					Name:  "rand-seed",
+					Usage: "Optionally seed the network's random number generator, so sampling is repeatable (--seed is the seed data)",
+				},
+				cli.IntFlag{
+					Name:  "workers, w",
+					Usage: "Optional number of goroutines to step the network with, for large networks. The sample is the same with any number.",
+				},
+				cli.BoolFlag{
+					Name:  "mmap",
+					Usage: "Memory-map a binary .nur network instead of loading it, for networks larger than RAM",
+				},
 			},
 			Before: func(c *cli.Context) error {
 				if c.Args().First() == "" {
@@ -165,7 +282,46 @@ func main() {
 					desiredLength = 10
 				}
 
-				return cmd.Sample(networkSaveFile, vocabSaveFile, seed, desiredLength)
+				return cmd.Sample(networkSaveFile, vocabSaveFile, seed, desiredLength, c.Int64("rand-seed"), c.IsSet("rand-seed"), c.Int("workers"), c.Bool("mmap"))
+			},
+		},
+		{
+			Name:        "readout",
+			Usage:       "Train a readout layer that decodes the network's firing into outputs",
+			Description: "The network is frozen while the readout trains, and is not changed. The readout is saved with the vocab, and used by sample.",
+			ArgsUsage:   "[network file]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "vocab, v",
+					Usage: "Vocab file to read the inputs from and save the readout to",
+				},
+				cli.StringFlag{
+					Name:  "data, d",
+					Usage: "Training data file",
+				},
+				cli.IntFlag{
+					Name:  "epochs, e",
+					Usage: "Optional most times to go through the training data, defaults to 100",
+				},
+				cli.Float64Flag{
+					Name:  "learning-rate",
+					Usage: "Optional amount each mistake changes the readout, defaults to 0.1",
+				},
+			},
+			Before: func(c *cli.Context) error {
+				if c.Args().First() == "" {
+					return errors.New("Missing network filename")
+				}
+				required := []string{"vocab", "data"}
+				for _, field := range required {
+					if c.String(field) == "" {
+						return errors.New("Missing required argument " + field)
+					}
+				}
+				return nil
+			},
+			Action: func(c *cli.Context) (err error) {
+				return cmd.Readout(c.Args().First(), c.String("vocab"), c.String("data"), c.Int("epochs"), c.Float64("learning-rate"))
 			},
 		},
 		{
@@ -227,6 +383,132 @@ func main() {
 				return err
 			},
 		},
+		{
+			Name:      "compact",
+			Usage:     "Renumber cells and synapses to reclaim the space left by pruning",
+			ArgsUsage: "[network file]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "vocab, v",
+					Usage: "Optional vocab file to update with the new cell IDs. A vocab trained on the network will not work with it after compacting, unless it is updated.",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Optional network output file, defaults to overwriting the network",
+				},
+				cli.StringFlag{
+					Name:  "vocab-output",
+					Usage: "Optional vocab output file, defaults to overwriting the vocab",
+				},
+			},
+			Before: func(c *cli.Context) error {
+				if c.Args().First() == "" {
+					return errors.New("Missing network filename")
+				}
+				return nil
+			},
+			Action: func(c *cli.Context) (err error) {
+				networkFile := c.Args().First()
+				vocabFile := c.String("vocab")
+				networkOutFile := c.String("output")
+				if networkOutFile == "" {
+					networkOutFile = networkFile
+				}
+				vocabOutFile := c.String("vocab-output")
+				if vocabOutFile == "" {
+					vocabOutFile = vocabFile
+				}
+
+				return cmd.Compact(networkFile, vocabFile, networkOutFile, vocabOutFile)
+			},
+		},
+		{
+			Name:      "prune",
+			Usage:     "Remove synapses that rarely fired, and the cells left without any synapses",
+			ArgsUsage: "[network file]",
+			Flags: []cli.Flag{
+				cli.UintFlag{
+					Name:  "min-activations, m",
+					Usage: "Synapses that fired fewer times than this are removed, defaults to 1 (never fired)",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Only report what would be removed",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Optional network output file, defaults to overwriting the network",
+				},
+			},
+			Before: func(c *cli.Context) error {
+				if c.Args().First() == "" {
+					return errors.New("Missing network filename")
+				}
+				return nil
+			},
+			Action: func(c *cli.Context) (err error) {
+				networkFile := c.Args().First()
+				networkOutFile := c.String("output")
+				if networkOutFile == "" {
+					networkOutFile = networkFile
+				}
+				minActivations := c.Uint("min-activations")
+				if !c.IsSet("min-activations") {
+					minActivations = 1
+				}
+
+				return cmd.Prune(networkFile, networkOutFile, minActivations, c.Bool("dry-run"))
+			},
+		},
+		{
+			Name:      "repair",
+			Usage:     "Fix the integrity of a network that will not load, and save it",
+			ArgsUsage: "[network file]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Only report what would be repaired",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Optional network output file, defaults to overwriting the network",
+				},
+			},
+			Before: func(c *cli.Context) error {
+				if c.Args().First() == "" {
+					return errors.New("Missing network filename")
+				}
+				return nil
+			},
+			Action: func(c *cli.Context) (err error) {
+				networkFile := c.Args().First()
+				networkOutFile := c.String("output")
+				if networkOutFile == "" {
+					networkOutFile = networkFile
+				}
+				return cmd.Repair(networkFile, networkOutFile, c.Bool("dry-run"))
+			},
+		},
+		{
+			Name:      "migrate",
+			Usage:     "Upgrade a saved network, and optionally its vocab, to the current schema version",
+			ArgsUsage: "[network file]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "vocab, v",
+					Usage: "Optional vocab file to upgrade too",
+				},
+			},
+			Before: func(c *cli.Context) error {
+				if c.Args().First() == "" {
+					return errors.New("Missing network filename")
+				}
+				return nil
+			},
+			Action: func(c *cli.Context) (err error) {
+				return cmd.Migrate(c.Args().First(), c.String("vocab"))
+			},
+		},
 		{
 			Name:      "inspect",
 			Usage:     "Get information about cells and synapses in a network. Prints the network in human readable format by default.",
@@ -244,6 +526,10 @@ func main() {
 					Name:  "tags, g",
 					Usage: "Print all cells that have a Tag property",
 				},
+				cli.BoolFlag{
+					Name:  "regions, r",
+					Usage: "Print how many cells are in each region, and how many synapses go from each region to the others",
+				},
 				cli.IntFlag{
 					Name:  "cell, c",
 					Usage: "Print info about a specific cell",
@@ -252,6 +538,14 @@ func main() {
 					Name:  "synapse, s",
 					Usage: "Print info about a specific synapse",
 				},
+				cli.BoolFlag{
+					Name:  "mmap",
+					Usage: "Memory-map a binary .nur network instead of loading it, for networks larger than RAM",
+				},
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "With --integrity, print every violation as JSON to stdout",
+				},
 			},
 			Before: func(c *cli.Context) error {
 				if c.Args().First() == "" {
@@ -262,7 +556,7 @@ func main() {
 			Action: func(c *cli.Context) (err error) {
 				net := c.Args().First()
 				log.Println("Reading network from", net)
-				return cmd.Inspect(net, c.Bool("integrity"), c.Bool("totals"), c.Bool("tags"), c.Int("cell"), c.Int("synapse"))
+				return cmd.Inspect(net, c.Bool("integrity"), c.Bool("totals"), c.Bool("tags"), c.Bool("regions"), c.Int("cell"), c.Int("synapse"), c.Bool("mmap"), c.Bool("json"))
 			},
 		},
 		{
@@ -274,6 +568,14 @@ func main() {
 					Name:  "n",
 					Usage: "Number of times to fire",
 				},
+				cli.Int64Flag{
+					Name:  "seed",
+					Usage: "Optionally seed the network's random number generator, so firing is repeatable",
+				},
+				cli.StringFlag{
+					Name:  "raster",
+					Usage: "Optional file to save every spike to, as step and cell. Format is by extension: .csv, .json, or compact binary",
+				},
 			},
 			Before: func(c *cli.Context) error {
 				if c.Args().First() == "" {
@@ -292,17 +594,20 @@ func main() {
 				if n == 0 {
 					n = 1
 				}
-				network, err := potential.LoadNetworkFromFile(net)
+				network, err := cmd.LoadNetwork(net)
 				if err != nil {
 					return err
 				}
+				if c.IsSet("seed") {
+					network.SetSeed(c.Int64("seed"))
+				}
 				cellInt, err := strconv.Atoi(cellString)
 				if err != nil {
 					return err
 				}
 				cell = potential.CellID(cellInt)
 
-				return cmd.FireCell(network, cell, n)
+				return cmd.FireCell(network, cell, n, c.String("raster"))
 			},
 		},
 		{
@@ -318,6 +623,10 @@ func main() {
 					Name:  "n",
 					Usage: "Number of times to fire",
 				},
+				cli.Int64Flag{
+					Name:  "seed",
+					Usage: "Optionally seed the network's random number generator, so firing is repeatable",
+				},
 			},
 			Before: func(c *cli.Context) error {
 				if c.Args().First() == "" {
@@ -340,7 +649,7 @@ func main() {
 					n = 1
 				}
 
-				network, err := potential.LoadNetworkFromFile(net)
+				network, err := cmd.LoadNetwork(net)
 				if err != nil {
 					return err
 				}
@@ -382,7 +691,7 @@ func main() {
 		{
 			Name:        "export",
 			Usage:       "Output a network to a different file format",
-			Description: "Valid formats: dot, json, default",
+			Description: "Valid formats: dot, json, binary (.nur), default (gzipped JSON)",
 			ArgsUsage:   "[network file] [format]",
 			Flags: []cli.Flag{
 				cli.StringFlag{
@@ -403,6 +712,9 @@ func main() {
 				if outFormatName == "default" {
 					outFormatName = "nur"
 				}
+				if outFormatName == "binary" {
+					outFormatName = "v2.nur"
+				}
 				outFile := c.String("output")
 				if outFile == "" {
 					outFile = strings.TrimSuffix(basename, filepath.Ext(basename)) + "." + outFormatName
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/ruffrey/nurtrace/potential"
+)
+
+// Compact renumbers a network's cells and synapses to reclaim pruned slots, and
+// updates the vocab to match when one is given.
+func Compact(networkFile, vocabFile, networkOutFile, vocabOutFile string) (err error) {
+	network, err := LoadNetwork(networkFile)
+	if err != nil {
+		return err
+	}
+	var vocab *potential.Vocabulary
+	if vocabFile != "" {
+		vocab, err = potential.LoadVocabFromFile(vocabFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	beforeCells := len(network.Cells)
+	beforeSynapses := len(network.Synapses)
+	cellIDs, _ := network.Compact()
+	log.Println("Compacted cells from", beforeCells, "to", len(network.Cells),
+		"and synapses from", beforeSynapses, "to", len(network.Synapses))
+
+	err = network.SaveToFile(networkOutFile)
+	if err != nil {
+		return err
+	}
+	if vocab != nil {
+		vocab.RemapCells(cellIDs)
+		err = vocab.SaveToFile(vocabOutFile)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
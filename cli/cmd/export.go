@@ -7,8 +7,6 @@ import (
 	"os"
 	"strconv"
 
-	"github.com/ruffrey/nurtrace/potential"
-
 	"github.com/awalterschulze/gographviz"
 )
 
@@ -16,7 +14,7 @@ const directed bool = false
 
 // Export takes a network and puts it into the requested format
 func Export(outFormat, networkFile, outFile string) (err error) {
-	network, err := potential.LoadNetworkFromFile(networkFile)
+	network, err := LoadNetwork(networkFile)
 	if err != nil {
 		return err
 	}
@@ -51,6 +49,8 @@ func Export(outFormat, networkFile, outFile string) (err error) {
 		return nil
 	case "json":
 		return network.SaveToFileReadable(outFile)
+	case "binary":
+		return network.SaveToFileBinary(outFile)
 	case "default":
 		return network.SaveToFile(outFile)
 	}
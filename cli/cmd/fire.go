@@ -7,8 +7,14 @@ import (
 )
 
 // FireCell fires a cell n times and prints all cells that it fires.
-func FireCell(network *potential.Network, cell potential.CellID, n int) (err error) {
+// When rasterFile is not empty, every spike is also saved to it.
+func FireCell(network *potential.Network, cell potential.CellID, n int, rasterFile string) (err error) {
 	network.ResetForTraining()
+	var raster *potential.SpikeRaster
+	if rasterFile != "" {
+		raster = potential.NewSpikeRaster()
+		network.AddObserver(raster)
+	}
 	cellArg := make(potential.FiringPattern)
 	cellArg[cell] = 1
 	for i := 0; i < n; i++ {
@@ -22,5 +28,9 @@ func FireCell(network *potential.Network, cell potential.CellID, n int) (err err
 		}
 		potential.FireNetworkUntilDone(network, cellArg)
 	}
+	if raster != nil {
+		log.Println("Saving", len(raster.Events), "spikes to", rasterFile)
+		return raster.SaveToFile(rasterFile)
+	}
 	return nil
 }
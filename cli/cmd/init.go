@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/ruffrey/nurtrace/potential"
+)
+
+// Init creates a new network with the requested topology and saves it, without training it.
+// When useSeed is true, the network is seeded with seed before it grows, so it is repeatable.
+func Init(networkFile, topology string, opts potential.TopologyOptions, lawsProfile string, lawOverrides []string, seed int64, useSeed bool) (err error) {
+	if _, err = os.Stat(networkFile); err == nil {
+		return errors.New("Network file " + networkFile + " already exists")
+	}
+
+	network := potential.NewNetwork()
+	if useSeed {
+		network.SetSeed(seed)
+	}
+	err = applyLaws(network.Laws, lawsProfile, lawOverrides)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Growing", topology, "network")
+	stats, err := network.GrowTopology(topology, opts)
+	if err != nil {
+		return err
+	}
+	stats.Print()
+
+	log.Println("Saving network to", networkFile)
+	return network.SaveToFile(networkFile)
+}
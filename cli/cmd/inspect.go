@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"strconv"
 
@@ -9,33 +11,45 @@ import (
 )
 
 // Inspect prints information about a network or requested components of the network.
-func Inspect(filename string, integrity bool, totals bool, allTags bool, cell int, synapse int) (err error) {
-	net, err := potential.LoadNetworkFromFile(filename)
-	if err != nil {
-		return err
+// With mapped, the network is memory-mapped instead of loaded, so it can be larger than RAM.
+// With asJSON, the integrity report is printed to stdout as JSON.
+func Inspect(filename string, integrity bool, totals bool, allTags bool, regions bool, cell int, synapse int, mapped bool, asJSON bool) (err error) {
+	if integrity && !mapped {
+		return inspectIntegrity(filename, asJSON)
 	}
 
-	if integrity {
-		ok, report := potential.CheckIntegrity(net)
-		if ok {
-			log.Println("Integrity OK - no dangling connections.")
-			return nil
+	var net *potential.Network
+	if mapped {
+		if integrity || regions {
+			return errors.New("Checking integrity and summarizing regions need the whole network, inspect it without --mmap")
 		}
-		report.Print()
-		return errors.New("Failed network integrity check")
+		net, err = potential.OpenMappedNetwork(filename)
+	} else {
+		net, err = LoadNetwork(filename)
+	}
+	if err != nil {
+		return err
 	}
+	defer net.Close()
 
 	if totals {
 		net.PrintTotals()
 		return nil
 	}
 
+	if regions {
+		for _, summary := range net.SummarizeRegions() {
+			summary.Print()
+		}
+		return nil
+	}
+
 	if allTags {
-		for _, c := range net.Cells {
+		net.EachCell(func(c *potential.Cell) {
 			if c.Tag != "" {
 				log.Println(c.Tag, c.ID)
 			}
-		}
+		})
 		return nil
 	}
 
@@ -44,7 +58,7 @@ func Inspect(filename string, integrity bool, totals bool, allTags bool, cell in
 		if !exists {
 			return errors.New("Cell " + strconv.Itoa(cell) + "does not exist")
 		}
-		c := net.Cells[potential.CellID(cell)]
+		c := net.GetCell(potential.CellID(cell))
 		log.Println(c)
 		return nil
 	}
@@ -54,7 +68,7 @@ func Inspect(filename string, integrity bool, totals bool, allTags bool, cell in
 		if !exists {
 			return errors.New("Synapse " + strconv.Itoa(synapse) + "does not exist")
 		}
-		s := net.Synapses[potential.SynapseID(synapse)]
+		s := net.GetSyn(potential.SynapseID(synapse))
 		log.Println(s)
 		return nil
 	}
@@ -62,3 +76,44 @@ func Inspect(filename string, integrity bool, totals bool, allTags bool, cell in
 	net.Print()
 	return nil
 }
+
+// inspectIntegrity checks a saved network, which may have bad integrity, since
+// that is what it is looking for. With --repair, the network is checked after
+// it is repaired.
+func inspectIntegrity(filename string, asJSON bool) error {
+	var ok bool
+	var report potential.IntegrityReport
+	if RepairOnLoad {
+		net, err := LoadNetwork(filename)
+		if err != nil {
+			return err
+		}
+		ok, report = potential.CheckIntegrity(net)
+	} else {
+		var err error
+		ok, report, err = potential.CheckFileIntegrity(filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else if ok {
+		log.Println("Integrity OK - no dangling connections.")
+		if report.Warnings() > 0 {
+			log.Println(report.Warnings(), "warnings:")
+			report.Print()
+		}
+	} else {
+		report.Print()
+	}
+	if !ok {
+		return errors.New("Failed network integrity check")
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/ruffrey/nurtrace/potential"
+)
+
+// RepairOnLoad makes LoadNetwork repair networks with bad integrity instead of
+// refusing to load them. It is set by the global --repair flag.
+var RepairOnLoad bool
+
+// LoadNetwork loads a network for a command, repairing it if RepairOnLoad is set,
+// and logs what was repaired.
+func LoadNetwork(networkFile string) (*potential.Network, error) {
+	if !RepairOnLoad {
+		return potential.LoadNetworkFromFile(networkFile)
+	}
+	network, report, err := potential.LoadNetworkFromFileRepaired(networkFile)
+	if err != nil {
+		return network, err
+	}
+	if report.Fixed() > 0 {
+		log.Println("Repaired", networkFile, "while loading it")
+		report.Print()
+	}
+	return network, nil
+}
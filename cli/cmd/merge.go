@@ -4,11 +4,11 @@ import "github.com/ruffrey/nurtrace/potential"
 
 // Merge merges two networks and also returns the diff
 func Merge(originalNetworkFilename, otherNetworkFilename string) (originalNetwork *potential.Network, diff potential.Diff, err error) {
-	originalNetwork, err = potential.LoadNetworkFromFile(originalNetworkFilename)
+	originalNetwork, err = LoadNetwork(originalNetworkFilename)
 	if err != nil {
 		return originalNetwork, diff, err
 	}
-	otherNetwork, err := potential.LoadNetworkFromFile(otherNetworkFilename)
+	otherNetwork, err := LoadNetwork(otherNetworkFilename)
 	if err != nil {
 		return originalNetwork, diff, err
 	}
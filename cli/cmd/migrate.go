@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/ruffrey/nurtrace/potential"
+)
+
+// Migrate rewrites a network, and optionally its vocab, at the current schema
+// version, and reports what changed.
+func Migrate(networkFile, vocabFile string) (err error) {
+	log.Println("Current schema version is", potential.CurrentSchemaVersion)
+	report, err := potential.MigrateNetworkFile(networkFile)
+	if err != nil {
+		return err
+	}
+	log.Println(networkFile, "format=", report.Format)
+	report.Print()
+
+	if vocabFile == "" {
+		return nil
+	}
+	report, err = potential.MigrateVocabFile(vocabFile)
+	if err != nil {
+		return err
+	}
+	log.Println(vocabFile)
+	report.Print()
+	return nil
+}
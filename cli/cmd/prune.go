@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"log"
+)
+
+// Prune removes synapses that fired fewer than minActivations times, and the cells
+// left without synapses. With dryRun, it only reports what would be removed.
+func Prune(networkFile, networkOutFile string, minActivations uint, dryRun bool) (err error) {
+	network, err := LoadNetwork(networkFile)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		report := network.FindInactive(minActivations)
+		log.Println("Dry run - nothing was removed")
+		report.Print()
+		for _, synapseID := range report.Synapses {
+			log.Println("  synapse", synapseID, "activations=", network.GetSyn(synapseID).ActivationHistory)
+		}
+		for _, cellID := range report.Cells {
+			log.Println("  cell", cellID)
+		}
+		return nil
+	}
+
+	report := network.PruneInactive(minActivations)
+	report.Print()
+	network.PrintTotals()
+
+	return network.SaveToFile(networkOutFile)
+}
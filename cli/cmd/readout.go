@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+
+	"github.com/ruffrey/nurtrace/potential"
+)
+
+// Readout trains a readout layer on a network's firing patterns for the training data,
+// and saves it with the vocab. The network is not changed.
+// epochs and learningRate override the defaults when they are more than zero.
+func Readout(networkFile, vocabFile, testDataFile string, epochs int, learningRate float64) (err error) {
+	testDataBytes, err := ioutil.ReadFile(testDataFile)
+	if err != nil {
+		return err
+	}
+	vocab, err := potential.LoadVocabFromFile(vocabFile)
+	if err != nil {
+		return err
+	}
+	vocab.Net, err = LoadNetwork(networkFile)
+	if err != nil {
+		return err
+	}
+	knownInputs, knownOutputs := len(vocab.Inputs), len(vocab.Outputs)
+	if err = vocab.AddTrainingData(testDataBytes); err != nil {
+		return err
+	}
+	// new inputs would grow cells onto a network that is not saved
+	if len(vocab.Inputs) != knownInputs || len(vocab.Outputs) != knownOutputs {
+		return errors.New("Training data has inputs or outputs the network was not trained on; train it first")
+	}
+
+	opts := potential.DefaultReadoutOptions()
+	if epochs > 0 {
+		opts.Epochs = epochs
+	}
+	if learningRate > 0 {
+		opts.LearningRate = learningRate
+	}
+	readout, accuracy, err := potential.TrainReadout(vocab, opts)
+	if err != nil {
+		return err
+	}
+	log.Println("Readout trained on", len(vocab.Samples), "samples,", len(readout.Cells), "cells, accuracy", accuracy)
+
+	vocab.Readout = readout
+	vocab.ClearSamples()
+	return vocab.SaveToFile(vocabFile)
+}
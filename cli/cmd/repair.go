@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/ruffrey/nurtrace/potential"
+)
+
+// Repair fixes the integrity of a network that will not load, and saves it. With
+// dryRun, it only reports what would be repaired.
+func Repair(networkFile, networkOutFile string, dryRun bool) (err error) {
+	network, report, err := potential.LoadNetworkFromFileRepaired(networkFile)
+	if err != nil {
+		return err
+	}
+	report.Print()
+	if dryRun {
+		log.Println("Dry run - nothing was saved")
+		return nil
+	}
+	if report.Fixed() == 0 && networkOutFile == networkFile {
+		return nil
+	}
+	log.Println("Saving repaired network to", networkOutFile)
+	return network.SaveToFile(networkOutFile)
+}
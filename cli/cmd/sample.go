@@ -7,17 +7,34 @@ import (
 )
 
 // Sample uses a pretrained network to generate a prediction based on user provided data.
-func Sample(networkSaveFile, vocabSaveFile string, seedText string, sampleLength int) (err error) {
+// When useRandSeed is true, the network's random number generator is seeded with randSeed.
+// workers is how many goroutines each step of the network is split across. With mapped,
+// the network is memory-mapped instead of loaded, so it can be larger than RAM.
+// Sampling never saves the network, so it does not learn either way, which keeps the
+// output the same with or without mapped.
+func Sample(networkSaveFile, vocabSaveFile string, seedText string, sampleLength int, randSeed int64, useRandSeed bool, workers int, mapped bool) (err error) {
 	var vocab *potential.Vocabulary
 	vocab, err = potential.LoadVocabFromFile(vocabSaveFile)
 	if err != nil {
 		return err
 	}
 	var network *potential.Network
-	network, err = potential.LoadNetworkFromFile(networkSaveFile)
+	if mapped {
+		network, err = potential.OpenMappedNetwork(networkSaveFile)
+	} else {
+		network, err = LoadNetwork(networkSaveFile)
+	}
 	if err != nil {
 		return err
 	}
+	// the same as a mapped network, which is read-only
+	network.Laws.PlasticityRule = "none"
+	network.Laws.SynapseDecayInterval = 0
+	defer network.Close()
+	if useRandSeed {
+		network.SetSeed(randSeed)
+	}
+	network.StepWorkers = workers
 
 	vocab.Net = network
 	output := potential.Sample(seedText, vocab, sampleLength)
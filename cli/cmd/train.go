@@ -15,7 +15,11 @@ import (
 )
 
 // Train trains a network and vocab set.
-func Train(networkInputFile, networkSaveFile, vocabSaveFile, testDataFile, doProfile string, initialNetworkNeurons int) (err error) {
+// When useSeed is true, the network and vocab are seeded with seed before anything random happens.
+// threads overrides the vocab's thread count when it is more than zero.
+// sleepEvery overrides how many merges happen between sleep phases when it is more than zero.
+// topology is how a new network is grown, see potential.Topologies.
+func Train(networkInputFile, networkSaveFile, vocabSaveFile, testDataFile, doProfile string, initialNetworkNeurons int, lawsProfile string, lawOverrides []string, seed int64, useSeed bool, threads int, sleepEvery int, topology string) (err error) {
 	// start by initializing the network from disk or whatever
 	var network *potential.Network
 	var vocab *potential.Vocabulary
@@ -38,20 +42,45 @@ func Train(networkInputFile, networkSaveFile, vocabSaveFile, testDataFile, doPro
 	} else {
 		log.Println("Loaded vocab from disk", vocabSaveFile)
 	}
+	if useSeed {
+		vocab.SetSeed(seed)
+	}
+	if threads > 0 {
+		vocab.Threads = threads
+	}
+	if sleepEvery > 0 {
+		vocab.SleepEvery = sleepEvery
+	}
 
 	// Load network
-	network, err = potential.LoadNetworkFromFile(networkSaveFile)
+	network, err = LoadNetwork(networkSaveFile)
 	if err != nil {
 		log.Println(err)
 		log.Println("Unable to load network from file; creating new one.")
 		network = potential.NewNetwork()
-		neuronsToAdd := initialNetworkNeurons
-		synapsesToAdd := 0
-		network.Grow(neuronsToAdd, laws.ComputedSynapsesPerCell, synapsesToAdd)
+		if useSeed {
+			network.SetSeed(seed)
+		}
+		err = applyLaws(network.Laws, lawsProfile, lawOverrides)
+		if err != nil {
+			return err
+		}
+		stats, err := network.GrowTopology(topology, potential.TopologyOptions{Cells: initialNetworkNeurons})
+		if err != nil {
+			return err
+		}
 		log.Println("Created network,", len(network.Cells), "cells",
 			len(network.Synapses), "synapses")
+		stats.Print()
 	} else {
 		log.Println("Loaded network from disk")
+		if useSeed {
+			network.SetSeed(seed)
+		}
+		err = applyLaws(network.Laws, lawsProfile, lawOverrides)
+		if err != nil {
+			return err
+		}
 		network.PrintTotals()
 	}
 
@@ -114,3 +143,23 @@ func Train(networkInputFile, networkSaveFile, vocabSaveFile, testDataFile, doPro
 
 	return nil
 }
+
+// applyLaws changes the laws using the profile file first, then the individual overrides.
+func applyLaws(l *laws.Laws, lawsProfile string, lawOverrides []string) (err error) {
+	if lawsProfile != "" {
+		log.Println("Reading laws profile", lawsProfile)
+		err = laws.LoadLawsFromFile(l, lawsProfile)
+		if err != nil {
+			log.Println("Unable to load laws profile", lawsProfile, err)
+			return err
+		}
+	}
+	err = l.SetAll(lawOverrides)
+	if err != nil {
+		return err
+	}
+	if _, err = potential.NewPlasticityRule(l.PlasticityRule); err != nil {
+		return err
+	}
+	return l.Validate()
+}
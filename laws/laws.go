@@ -11,6 +11,10 @@ in which all neural network will live.
 
 Any ratios or constants that keep the network reliably predictable across hardware
 and training or sampling sessions should be in this file.
+
+These constants are the default profile. At runtime, each network carries its own
+`Laws` (see profile.go), which can be loaded from a file or overridden, so read
+laws from the network rather than from these constants.
 */
 
 // ActualSynapseMin and ActualSynapseMax helps make math less intensive if there is
@@ -55,8 +59,9 @@ SynapseLearnRate is how much a synapse should get bumped when it is being reinfo
 This is an absolute value because the synapse may be positive or negative, and this
 value will be how much it is bumped away from zero.
 
-When de-reinforcing or degrading a synapse, it will get reduced at 1/2 the distance
-to zero, until it is 2, then it will become zero.
+When de-reinforcing or degrading a synapse, it gets moved toward zero by a ratio
+of the distance (see SynapseWeakenRatio and SynapseDecayRatio), until it is within
+the learn rate of zero, then it will become zero and be pruned.
 */
 const SynapseLearnRate int16 = 2
 
@@ -65,6 +70,26 @@ CellFireVoltageThreshold represents the millivolts where an action potential wil
 */
 const CellFireVoltageThreshold int = 1000
 
+/*
+CellRefractorySteps is how many steps after a cell fires that it ignores
+incoming voltage - its absolute refractory period. The synapses onto it wait
+until it can fire again. Zero lets a cell fire on back to back steps.
+*/
+const CellRefractorySteps uint8 = 1
+
+/*
+ThresholdAdaptation is how many millivolts a cell's fire threshold goes up each
+time it fires, so a cell that fires a lot gets harder to fire (spike-frequency
+adaptation). Zero turns adaptation off.
+*/
+const ThresholdAdaptation int = 0
+
+/*
+ThresholdRecoveryRatio is how much of the distance back to
+CellFireVoltageThreshold an adapted threshold recovers each step.
+*/
+const ThresholdRecoveryRatio float64 = 0.2
+
 /*
 CellRestingVoltage is what a neuron gets reset to after it has fired.
 */
@@ -124,3 +149,93 @@ const NoiseRatio float64 = 0.01
 TrainingMergeBackIteration is the point at which we reset a network during training.
 */
 const TrainingMergeBackIteration = 10
+
+/*
+SynapseDelayMin and SynapseDelayMax are the range of conduction delays, in steps,
+given to newly grown synapses. A delay of zero means the synapse applies its
+voltage on the very next step after its axon cell fires.
+*/
+const SynapseDelayMin uint8 = 0
+
+// SynapseDelayMax is documented above.
+const SynapseDelayMax uint8 = 0
+
+/*
+SynapseDelayDistribution is how delays are picked between SynapseDelayMin and
+SynapseDelayMax when growing synapses.
+
+- `uniform` - every delay in the range is equally likely
+- `exponential` - short delays are much more likely than long ones
+*/
+const SynapseDelayDistribution = "uniform"
+
+/*
+SynapseWeakenRatio is how much of the distance to zero a synapse loses when it
+delivered voltage to a cell that did not fire. This is the anti-Hebbian
+opposite of reinforcing the synapses that made a cell fire.
+
+Zero turns weakening off. At 0.5, a synapse is halved each time.
+*/
+const SynapseWeakenRatio float64 = 0
+
+/*
+SynapseDecayRatio is how much of the distance to zero an idle synapse loses
+every SynapseDecayInterval steps. A synapse is idle if it did not deliver any
+voltage since the last decay.
+*/
+const SynapseDecayRatio float64 = 0
+
+/*
+SynapseDecayInterval is how many steps pass between synapse decays. Zero turns
+decay off.
+*/
+const SynapseDecayInterval int = 0
+
+/*
+PlasticityRule is the name of the learning rule a network uses to change its
+synapses as it fires.
+
+- `hebbian` - reinforce the synapses that made a cell fire, and weaken the ones
+onto a cell that did not
+- `stdp` - spike-timing-dependent plasticity, see the STDP laws below
+- `none` - the synapses never learn, so the network is frozen
+*/
+const PlasticityRule = "hebbian"
+
+/*
+STDPWindow is how many steps apart two spikes can be and still change the
+synapse between them, with the `stdp` plasticity rule.
+*/
+const STDPWindow uint8 = 20
+
+/*
+STDPPotentiation is the most millivolts a synapse is moved away from zero when
+its axon cell spikes right before its dendrite cell.
+*/
+const STDPPotentiation int16 = 10
+
+/*
+STDPDepression is the most millivolts a synapse is moved toward zero when its
+dendrite cell spikes right before its axon cell. It is a little stronger than
+potentiation so random firing weakens the network overall.
+*/
+const STDPDepression int16 = 12
+
+/*
+STDPTimeConstant is how many steps it takes for the potentiation or depression
+to fall off to about a third, as spikes get further apart.
+*/
+const STDPTimeConstant float64 = 5
+
+/*
+DalesLaw makes every new cell either excitatory or inhibitory, instead of
+mixed, when the network grows. The synapses grown from a cell all have the
+sign of its type, so a cell can no longer both excite and inhibit.
+*/
+const DalesLaw = false
+
+/*
+InhibitoryCellRatio is the fraction of new cells that are inhibitory when
+DalesLaw is on. The rest are excitatory. Cortex is roughly 20% inhibitory.
+*/
+const InhibitoryCellRatio float64 = 0.2
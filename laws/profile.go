@@ -0,0 +1,316 @@
+package laws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+/*
+Laws is a runtime profile of all the ratios and constants in this package.
+
+A profile is attached to every network and saved with it, so the universe
+a network was trained in travels with the network. The constants above are
+the default profile, returned by `DefaultLaws()`.
+*/
+type Laws struct {
+	NewSynapseMinMillivolts       int
+	NewSynapseMaxMillivolts       int
+	IdealCellSynapseBalance       float64
+	SynapseLearnRate              int16
+	CellFireVoltageThreshold      int
+	CellRestingVoltage            int16
+	CellRefractorySteps           uint8
+	ThresholdAdaptation           int
+	ThresholdRecoveryRatio        float64
+	MaxDepthFromInputToOutput     uint8
+	MaxPostFireSteps              int
+	FiringIterationsPerSample     int
+	PatternSimilarityLimit        float64
+	InitialCellCountPerInput      int
+	InputCellDifferentiationCount int
+	NoiseRatio                    float64
+	TrainingMergeBackIteration    int
+	SynapseDelayMin               uint8
+	SynapseDelayMax               uint8
+	SynapseDelayDistribution      string
+	SynapseWeakenRatio            float64
+	SynapseDecayRatio             float64
+	SynapseDecayInterval          int
+	PlasticityRule                string
+	STDPWindow                    uint8
+	STDPPotentiation              int16
+	STDPDepression                int16
+	STDPTimeConstant              float64
+	DalesLaw                      bool
+	InhibitoryCellRatio           float64
+}
+
+/*
+DefaultLaws returns a new profile populated with the constants in this package.
+*/
+func DefaultLaws() *Laws {
+	return &Laws{
+		NewSynapseMinMillivolts:       NewSynapseMinMillivolts,
+		NewSynapseMaxMillivolts:       NewSynapseMaxMillivolts,
+		IdealCellSynapseBalance:       IdealCellSynapseBalance,
+		SynapseLearnRate:              SynapseLearnRate,
+		CellFireVoltageThreshold:      CellFireVoltageThreshold,
+		CellRestingVoltage:            CellRestingVoltage,
+		CellRefractorySteps:           CellRefractorySteps,
+		ThresholdAdaptation:           ThresholdAdaptation,
+		ThresholdRecoveryRatio:        ThresholdRecoveryRatio,
+		MaxDepthFromInputToOutput:     MaxDepthFromInputToOutput,
+		MaxPostFireSteps:              MaxPostFireSteps,
+		FiringIterationsPerSample:     FiringIterationsPerSample,
+		PatternSimilarityLimit:        PatternSimilarityLimit,
+		InitialCellCountPerInput:      InitialCellCountPerInput,
+		InputCellDifferentiationCount: InputCellDifferentiationCount,
+		NoiseRatio:                    NoiseRatio,
+		TrainingMergeBackIteration:    TrainingMergeBackIteration,
+		SynapseDelayMin:               SynapseDelayMin,
+		SynapseDelayMax:               SynapseDelayMax,
+		SynapseDelayDistribution:      SynapseDelayDistribution,
+		SynapseWeakenRatio:            SynapseWeakenRatio,
+		SynapseDecayRatio:             SynapseDecayRatio,
+		SynapseDecayInterval:          SynapseDecayInterval,
+		PlasticityRule:                PlasticityRule,
+		STDPWindow:                    STDPWindow,
+		STDPPotentiation:              STDPPotentiation,
+		STDPDepression:                STDPDepression,
+		STDPTimeConstant:              STDPTimeConstant,
+		DalesLaw:                      DalesLaw,
+		InhibitoryCellRatio:           InhibitoryCellRatio,
+	}
+}
+
+/*
+Clone returns a copy of the profile that does not share memory with the original.
+*/
+func (l *Laws) Clone() *Laws {
+	c := *l
+	return &c
+}
+
+/*
+ActualSynapseMin is the runtime equivalent of the ActualSynapseMin constant.
+*/
+func (l *Laws) ActualSynapseMin() int16 {
+	return -32767 + l.SynapseLearnRate
+}
+
+/*
+ActualSynapseMax is the runtime equivalent of the ActualSynapseMax constant.
+*/
+func (l *Laws) ActualSynapseMax() int16 {
+	return 32766 - l.SynapseLearnRate
+}
+
+/*
+ComputedSynapsesPerCell is the runtime equivalent of the ComputedSynapsesPerCell
+variable.
+*/
+func (l *Laws) ComputedSynapsesPerCell() int {
+	return int(math.Ceil(1 / l.IdealCellSynapseBalance))
+}
+
+/*
+Validate makes sure a profile will not break the network.
+
+Most importantly, it keeps the guarantee that adding the learn rate to
+any synapse between ActualSynapseMin and ActualSynapseMax can never
+overflow the int16.
+*/
+func (l *Laws) Validate() error {
+	// the learn rate gets added to and subtracted from synapses near the int16
+	// bounds, so it must leave room on both sides.
+	if l.SynapseLearnRate <= 0 || l.SynapseLearnRate > math.MaxInt16/2 {
+		return fmt.Errorf("SynapseLearnRate must be between 1 and %d, got %d",
+			math.MaxInt16/2, l.SynapseLearnRate)
+	}
+	min := int(l.ActualSynapseMin())
+	max := int(l.ActualSynapseMax())
+	if l.NewSynapseMinMillivolts > l.NewSynapseMaxMillivolts {
+		return fmt.Errorf("NewSynapseMinMillivolts (%d) cannot be more than NewSynapseMaxMillivolts (%d)",
+			l.NewSynapseMinMillivolts, l.NewSynapseMaxMillivolts)
+	}
+	if l.NewSynapseMinMillivolts < min || l.NewSynapseMaxMillivolts > max {
+		return fmt.Errorf("new synapse millivolts must be between %d and %d", min, max)
+	}
+	// a single synapse is grown with the threshold as its weight
+	if l.CellFireVoltageThreshold <= 0 || l.CellFireVoltageThreshold > max {
+		return fmt.Errorf("CellFireVoltageThreshold must be between 1 and %d, got %d",
+			max, l.CellFireVoltageThreshold)
+	}
+	if int(l.CellRestingVoltage) < min || int(l.CellRestingVoltage) >= l.CellFireVoltageThreshold {
+		return fmt.Errorf("CellRestingVoltage must be between %d and CellFireVoltageThreshold, got %d",
+			min, l.CellRestingVoltage)
+	}
+	// an adapted threshold still has to be reachable by a synapse
+	if l.ThresholdAdaptation < 0 || l.ThresholdAdaptation > max {
+		return fmt.Errorf("ThresholdAdaptation must be between 0 and %d, got %d",
+			max, l.ThresholdAdaptation)
+	}
+	if l.ThresholdRecoveryRatio <= 0 || l.ThresholdRecoveryRatio > 1 {
+		return fmt.Errorf("ThresholdRecoveryRatio must be more than 0 and at most 1, got %f",
+			l.ThresholdRecoveryRatio)
+	}
+	if l.IdealCellSynapseBalance <= 0 || l.IdealCellSynapseBalance > 1 {
+		return fmt.Errorf("IdealCellSynapseBalance must be more than 0 and at most 1, got %f",
+			l.IdealCellSynapseBalance)
+	}
+	if l.PatternSimilarityLimit < 0 || l.PatternSimilarityLimit > 1 {
+		return fmt.Errorf("PatternSimilarityLimit must be between 0 and 1, got %f",
+			l.PatternSimilarityLimit)
+	}
+	if l.NoiseRatio < 0 || l.NoiseRatio > 1 {
+		return fmt.Errorf("NoiseRatio must be between 0 and 1, got %f", l.NoiseRatio)
+	}
+	// voltage decay divides by this
+	if l.FiringIterationsPerSample < 1 {
+		return fmt.Errorf("FiringIterationsPerSample must be at least 1, got %d",
+			l.FiringIterationsPerSample)
+	}
+	// training progress is the modulo of this
+	if l.TrainingMergeBackIteration < 1 {
+		return fmt.Errorf("TrainingMergeBackIteration must be at least 1, got %d",
+			l.TrainingMergeBackIteration)
+	}
+	if l.MaxPostFireSteps < 0 || l.InitialCellCountPerInput < 0 || l.InputCellDifferentiationCount < 0 {
+		return fmt.Errorf("MaxPostFireSteps, InitialCellCountPerInput and InputCellDifferentiationCount cannot be negative")
+	}
+	if l.SynapseDelayMin > l.SynapseDelayMax {
+		return fmt.Errorf("SynapseDelayMin (%d) cannot be more than SynapseDelayMax (%d)",
+			l.SynapseDelayMin, l.SynapseDelayMax)
+	}
+	if l.SynapseDelayDistribution != "uniform" && l.SynapseDelayDistribution != "exponential" {
+		return fmt.Errorf("SynapseDelayDistribution must be uniform or exponential, got %s",
+			l.SynapseDelayDistribution)
+	}
+	if l.SynapseWeakenRatio < 0 || l.SynapseWeakenRatio > 1 {
+		return fmt.Errorf("SynapseWeakenRatio must be between 0 and 1, got %f", l.SynapseWeakenRatio)
+	}
+	if l.SynapseDecayRatio < 0 || l.SynapseDecayRatio > 1 {
+		return fmt.Errorf("SynapseDecayRatio must be between 0 and 1, got %f", l.SynapseDecayRatio)
+	}
+	if l.SynapseDecayInterval < 0 {
+		return fmt.Errorf("SynapseDecayInterval cannot be negative, got %d", l.SynapseDecayInterval)
+	}
+	// the rule itself is looked up by name in the potential package
+	if l.PlasticityRule == "" {
+		return fmt.Errorf("PlasticityRule cannot be empty")
+	}
+	if l.STDPPotentiation < 0 || l.STDPDepression < 0 {
+		return fmt.Errorf("STDPPotentiation and STDPDepression cannot be negative")
+	}
+	if l.STDPTimeConstant <= 0 {
+		return fmt.Errorf("STDPTimeConstant must be more than 0, got %f", l.STDPTimeConstant)
+	}
+	if l.InhibitoryCellRatio < 0 || l.InhibitoryCellRatio > 1 {
+		return fmt.Errorf("InhibitoryCellRatio must be between 0 and 1, got %f", l.InhibitoryCellRatio)
+	}
+	return nil
+}
+
+/*
+Set changes a single law by name, parsing the value from a string. Names are
+not case sensitive. This is useful for overriding laws from the command line.
+*/
+func (l *Laws) Set(name string, value string) error {
+	field := reflect.ValueOf(l).Elem().FieldByNameFunc(func(fieldName string) bool {
+		return strings.EqualFold(fieldName, name)
+	})
+	if !field.IsValid() {
+		return fmt.Errorf("Unknown law %s", name)
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int16:
+		v, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("Bad value for law %s: %s", name, err)
+		}
+		field.SetInt(v)
+	case reflect.Uint8:
+		v, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("Bad value for law %s: %s", name, err)
+		}
+		field.SetUint(v)
+	case reflect.Float64:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("Bad value for law %s: %s", name, err)
+		}
+		field.SetFloat(v)
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Bad value for law %s: %s", name, err)
+		}
+		field.SetBool(v)
+	default:
+		return fmt.Errorf("Law %s cannot be set", name)
+	}
+	return nil
+}
+
+/*
+SetAll applies a list of `Name=value` overrides, in order.
+*/
+func (l *Laws) SetAll(overrides []string) error {
+	for _, o := range overrides {
+		parts := strings.SplitN(o, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Law override should look like Name=value - %s", o)
+		}
+		err := l.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+LoadLawsFromFile reads a profile from a JSON or YAML file (by extension)
+on top of the supplied laws, then validates the result.
+
+Only the laws in the file are changed, so a profile can be partial.
+*/
+func LoadLawsFromFile(l *Laws, filename string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".yml" || ext == ".yaml" {
+		// Going through JSON means the field names match the same way for
+		// both formats.
+		var asMap map[string]interface{}
+		err = yaml.Unmarshal(b, &asMap)
+		if err != nil {
+			return err
+		}
+		b, err = json.Marshal(asMap)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = json.Unmarshal(b, l)
+	if err != nil {
+		return err
+	}
+	return l.Validate()
+}
@@ -0,0 +1,142 @@
+package laws
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DefaultLaws(t *testing.T) {
+	t.Run("matches the constants", func(t *testing.T) {
+		l := DefaultLaws()
+		assert.Equal(t, SynapseLearnRate, l.SynapseLearnRate)
+		assert.Equal(t, ActualSynapseMin, l.ActualSynapseMin())
+		assert.Equal(t, ActualSynapseMax, l.ActualSynapseMax())
+		assert.Equal(t, ComputedSynapsesPerCell, l.ComputedSynapsesPerCell())
+		assert.Equal(t, CellRestingVoltage, l.CellRestingVoltage)
+	})
+	t.Run("is valid", func(t *testing.T) {
+		assert.NoError(t, DefaultLaws().Validate())
+	})
+}
+
+func Test_LawsValidate(t *testing.T) {
+	t.Run("learn rate that could overflow int16 is invalid", func(t *testing.T) {
+		l := DefaultLaws()
+		l.SynapseLearnRate = 20000
+		assert.Error(t, l.Validate())
+		l.SynapseLearnRate = 0
+		assert.Error(t, l.Validate())
+	})
+	t.Run("threshold above the synapse max is invalid", func(t *testing.T) {
+		l := DefaultLaws()
+		l.CellFireVoltageThreshold = int(l.ActualSynapseMax()) + 1
+		assert.Error(t, l.Validate())
+	})
+	t.Run("new synapse range must be in order", func(t *testing.T) {
+		l := DefaultLaws()
+		l.NewSynapseMinMillivolts = 10
+		l.NewSynapseMaxMillivolts = 1
+		assert.Error(t, l.Validate())
+	})
+	t.Run("delay range must be in order and distribution known", func(t *testing.T) {
+		l := DefaultLaws()
+		l.SynapseDelayMin = 3
+		assert.Error(t, l.Validate())
+		l.SynapseDelayMax = 3
+		assert.NoError(t, l.Validate())
+		l.SynapseDelayDistribution = "gaussian"
+		assert.Error(t, l.Validate())
+	})
+	t.Run("weaken and decay ratios must be between 0 and 1", func(t *testing.T) {
+		l := DefaultLaws()
+		l.SynapseWeakenRatio = 1.5
+		assert.Error(t, l.Validate())
+		l.SynapseWeakenRatio = 0.5
+		l.SynapseDecayRatio = -0.1
+		assert.Error(t, l.Validate())
+		l.SynapseDecayRatio = 0.1
+		l.SynapseDecayInterval = -1
+		assert.Error(t, l.Validate())
+		l.SynapseDecayInterval = 100
+		assert.NoError(t, l.Validate())
+	})
+	t.Run("inhibitory cell ratio must be between 0 and 1", func(t *testing.T) {
+		l := DefaultLaws()
+		l.InhibitoryCellRatio = 1.2
+		assert.Error(t, l.Validate())
+		l.InhibitoryCellRatio = 1
+		assert.NoError(t, l.Validate())
+	})
+	t.Run("threshold adaptation cannot be negative and must recover", func(t *testing.T) {
+		l := DefaultLaws()
+		l.ThresholdAdaptation = -5
+		assert.Error(t, l.Validate())
+		l.ThresholdAdaptation = 50
+		l.ThresholdRecoveryRatio = 0
+		assert.Error(t, l.Validate())
+		l.ThresholdRecoveryRatio = 0.5
+		assert.NoError(t, l.Validate())
+	})
+	t.Run("zero firing iterations is invalid", func(t *testing.T) {
+		l := DefaultLaws()
+		l.FiringIterationsPerSample = 0
+		assert.Error(t, l.Validate())
+	})
+}
+
+func Test_LawsSet(t *testing.T) {
+	t.Run("sets fields of each kind by name without case", func(t *testing.T) {
+		l := DefaultLaws()
+		assert.NoError(t, l.SetAll([]string{
+			"synapselearnrate=4",
+			"NoiseRatio=0.5",
+			"MaxDepthFromInputToOutput = 3",
+			"CellFireVoltageThreshold=2000",
+			"DalesLaw=true",
+		}))
+		assert.Equal(t, int16(4), l.SynapseLearnRate)
+		assert.Equal(t, 0.5, l.NoiseRatio)
+		assert.Equal(t, uint8(3), l.MaxDepthFromInputToOutput)
+		assert.Equal(t, 2000, l.CellFireVoltageThreshold)
+		assert.True(t, l.DalesLaw)
+	})
+	t.Run("unknown law or bad value returns an error", func(t *testing.T) {
+		l := DefaultLaws()
+		assert.Error(t, l.Set("Gravity", "9.8"))
+		assert.Error(t, l.Set("SynapseLearnRate", "lots"))
+		assert.Error(t, l.SetAll([]string{"NoiseRatio"}))
+	})
+}
+
+func Test_LoadLawsFromFile(t *testing.T) {
+	t.Run("loads a partial JSON profile", func(t *testing.T) {
+		filename := "_laws.test.json"
+		defer os.Remove(filename)
+		err := ioutil.WriteFile(filename, []byte(`{"SynapseLearnRate": 3}`), os.ModePerm)
+		assert.NoError(t, err)
+		l := DefaultLaws()
+		assert.NoError(t, LoadLawsFromFile(l, filename))
+		assert.Equal(t, int16(3), l.SynapseLearnRate)
+		assert.Equal(t, NoiseRatio, l.NoiseRatio)
+	})
+	t.Run("loads a YAML profile", func(t *testing.T) {
+		filename := "_laws.test.yml"
+		defer os.Remove(filename)
+		err := ioutil.WriteFile(filename, []byte("MaxPostFireSteps: 9\nNoiseRatio: 0.2\n"), os.ModePerm)
+		assert.NoError(t, err)
+		l := DefaultLaws()
+		assert.NoError(t, LoadLawsFromFile(l, filename))
+		assert.Equal(t, 9, l.MaxPostFireSteps)
+		assert.Equal(t, 0.2, l.NoiseRatio)
+	})
+	t.Run("invalid profile returns an error", func(t *testing.T) {
+		filename := "_laws_bad.test.json"
+		defer os.Remove(filename)
+		err := ioutil.WriteFile(filename, []byte(`{"NoiseRatio": 7}`), os.ModePerm)
+		assert.NoError(t, err)
+		assert.Error(t, LoadLawsFromFile(DefaultLaws(), filename))
+	})
+}
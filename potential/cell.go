@@ -2,8 +2,6 @@ package potential
 
 import (
 	"fmt"
-
-	"github.com/ruffrey/nurtrace/laws"
 	"math"
 )
 
@@ -12,6 +10,56 @@ CellID should be unique for all cells in a network.
 */
 type CellID uint32
 
+/*
+CellType is what kind of synapses a cell may grow, following Dale's law that a
+neuron releases the same transmitters at all of its synapses.
+*/
+type CellType uint8
+
+const (
+	// MixedCell may have both excitatory and inhibitory synapses. It is what
+	// every cell was before cell types, so it is the zero value.
+	MixedCell CellType = iota
+	// ExcitatoryCell only has positive synapses.
+	ExcitatoryCell
+	// InhibitoryCell only has negative synapses.
+	InhibitoryCell
+)
+
+func (cellType CellType) String() string {
+	switch cellType {
+	case MixedCell:
+		return "mixed"
+	case ExcitatoryCell:
+		return "excitatory"
+	case InhibitoryCell:
+		return "inhibitory"
+	}
+	return fmt.Sprintf("CellType(%d)", uint8(cellType))
+}
+
+/*
+signed gives millivolts the sign that synapses from this type of cell must
+have. Mixed cells keep the sign as it is.
+*/
+func (cellType CellType) signed(millivolts int16) int16 {
+	if cellType == ExcitatoryCell && millivolts < 0 {
+		return -millivolts
+	}
+	if cellType == InhibitoryCell && millivolts > 0 {
+		return -millivolts
+	}
+	return millivolts
+}
+
+/*
+allows is whether a synapse from this type of cell may have the millivolts.
+Zero is allowed for every type.
+*/
+func (cellType CellType) allows(millivolts int16) bool {
+	return cellType.signed(millivolts) == millivolts
+}
+
 /*
 Cell holds voltage, receives input from Dendrites, and upon reaching the activation voltage,
 fires an action potential cycle and its axon synapses push voltage to the dendrites it connects
@@ -34,10 +82,27 @@ type Cell struct {
 	   Immortal means this cell cannot be pruned. It should only be by perceptors and
 	   receptors.
 	*/
-	Immortal   bool
+	Immortal bool
+	/*
+	   Type limits the sign of the synapses this cell sends. See CellType.
+	*/
+	Type       CellType
 	Network    *Network `json:"-"` // skip circular reference in JSON
 	Voltage    int16    // unnecessary to recreate cell
 	activating bool     // unnecessary to recreate cell
+	/*
+	   Threshold is the voltage this cell fires at. It goes up by the
+	   `ThresholdAdaptation` law every time the cell fires, and recovers back to
+	   the `CellFireVoltageThreshold` law as the network steps. Zero means the law.
+	*/
+	Threshold int
+	/*
+	   RefractorySteps is how many steps this cell ignores incoming voltage after
+	   it fires. Zero means the `CellRefractorySteps` law.
+	*/
+	RefractorySteps uint8
+	// refractoryLeft counts down the steps left in the refractory period
+	refractoryLeft uint8
 	/*
 	  DendriteSynapses are this cell's inputs. They are IDs of synapses.
 	*/
@@ -63,6 +128,11 @@ type Cell struct {
 		Purely informational, for use when testing or debugging.
 	*/
 	Tag string
+	/*
+		Region is the name of the Region this cell belongs to, if any. Set it with
+		`Network.SetCellRegion`.
+	*/
+	Region string
 }
 
 /*
@@ -76,7 +146,7 @@ func NewCell(network *Network) *Cell {
 		ID:               CellID(len(network.Cells)),
 		Network:          network,
 		Immortal:         false,
-		Voltage:          laws.CellRestingVoltage,
+		Voltage:          network.Laws.CellRestingVoltage,
 		activating:       false,
 		DendriteSynapses: make(map[SynapseID]bool),
 		AxonSynapses:     make(map[SynapseID]bool),
@@ -85,7 +155,11 @@ func NewCell(network *Network) *Cell {
 	}
 	cell := &c
 	network.Cells = append(network.Cells, cell)
+	network.regionCells = nil
 	network.cellMux.Unlock()
+	for _, observer := range network.observers {
+		observer.CellCreated(network, cell.ID)
+	}
 	return cell
 }
 
@@ -105,11 +179,74 @@ func (cell *Cell) FireActionPotential() {
 	for synapseID := range cell.AxonSynapses {
 		cell.Network.AddSynapseToNextStep(synapseID)
 	}
+
+	cell.adaptThreshold()
+	cell.Network.plasticityRule().Spiked(cell.Network, cell.ID)
+}
+
+/*
+threshold is the voltage the cell fires at right now.
+*/
+func (cell *Cell) threshold() int {
+	if cell.Threshold == 0 {
+		return cell.Network.Laws.CellFireVoltageThreshold
+	}
+	return cell.Threshold
+}
+
+/*
+isAdapted is whether the threshold is still up from firing.
+*/
+func (cell *Cell) isAdapted() bool {
+	return cell.threshold() > cell.Network.Laws.CellFireVoltageThreshold
+}
+
+/*
+refractorySteps is how long the refractory period of the cell is.
+*/
+func (cell *Cell) refractorySteps() uint8 {
+	if cell.RefractorySteps == 0 {
+		return cell.Network.Laws.CellRefractorySteps
+	}
+	return cell.RefractorySteps
+}
+
+/*
+adaptThreshold raises the threshold after the cell fires. The cell becomes active
+so Step can bring the threshold back down.
+*/
+func (cell *Cell) adaptThreshold() {
+	l := cell.Network.Laws
+	if l.ThresholdAdaptation == 0 {
+		return
+	}
+	threshold := cell.threshold() + l.ThresholdAdaptation
+	if max := int(l.ActualSynapseMax()); threshold > max {
+		threshold = max
+	}
+	cell.Threshold = threshold
+	cell.Network.markActive(cell)
+}
+
+/*
+recoverThreshold moves an adapted threshold back toward the
+`CellFireVoltageThreshold` law by the `ThresholdRecoveryRatio` law.
+*/
+func (cell *Cell) recoverThreshold() {
+	if !cell.isAdapted() {
+		return
+	}
+	l := cell.Network.Laws
+	over := float64(cell.threshold() - l.CellFireVoltageThreshold)
+	// truncating makes sure it always gets all the way back
+	cell.Threshold = l.CellFireVoltageThreshold + int(over*(1-l.ThresholdRecoveryRatio))
 }
 
 func (cell *Cell) String() string {
 	s := fmt.Sprintf("Cell %d", cell.ID)
 	s += fmt.Sprintf("\n  Immortal=%t", cell.Immortal)
+	s += fmt.Sprintf("\n  Type=%s", cell.Type)
+	s += fmt.Sprintf("\n  Threshold=%d", cell.threshold())
 	s += fmt.Sprintf("\n  Voltage=%d", cell.Voltage)
 	s += fmt.Sprintf("\n  Tag=%s", cell.Tag)
 
@@ -159,7 +296,11 @@ func (network *Network) PruneCell(cellID CellID) {
 
 	network.cellMux.Lock()
 	network.Cells[cellID] = nil
+	network.regionCells = nil
 	network.cellMux.Unlock()
+	for _, observer := range network.observers {
+		observer.CellPruned(network, cellID)
+	}
 }
 
 /*
@@ -168,19 +309,22 @@ the refractory period after it fired.
 */
 func (cell *Cell) postRefractoryReset() {
 	cell.activating = false
-	cell.Voltage = laws.CellRestingVoltage
+	cell.refractoryLeft = 0
+	cell.Voltage = cell.Network.Laws.CellRestingVoltage
 }
 
 /*
 towardResting moves cell voltage in the direction of the resting voltage.
 */
 func (cell *Cell) towardResting() {
-	if cell.Voltage == laws.CellRestingVoltage {
+	l := cell.Network.Laws
+	if cell.Voltage == l.CellRestingVoltage {
 		return
 	}
-	isOver := cell.Voltage > laws.CellRestingVoltage
-	amountOver := int16(math.Abs(float64(cell.Voltage - laws.CellRestingVoltage)))
-	movementAmount := amountOver / int16(laws.FiringIterationsPerSample*2)
+	isOver := cell.Voltage > l.CellRestingVoltage
+	amountOver := int16(math.Abs(float64(cell.Voltage - l.CellRestingVoltage)))
+	// divided as int, so a large FiringIterationsPerSample cannot wrap around
+	movementAmount := int16(int(amountOver) / (l.FiringIterationsPerSample * 2))
 	if isOver {
 		cell.Voltage -= movementAmount
 		return
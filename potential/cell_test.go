@@ -142,4 +142,44 @@ func Test_CellTowardResting(t *testing.T) {
 		cell.towardResting()
 		assert.Equal(t, int16(-81), cell.Voltage)
 	})
+	t.Run("large FiringIterationsPerSample does not wrap the divisor", func(t *testing.T) {
+		network := NewNetwork()
+		cell := NewCell(network)
+		resting := network.Laws.CellRestingVoltage
+
+		for _, iterations := range []int{16384, 20000, 32768} {
+			network.Laws.FiringIterationsPerSample = iterations
+			cell.Voltage = resting + 30000
+			cell.towardResting()
+			assert.True(t, cell.Voltage <= resting+30000, iterations)
+			assert.True(t, cell.Voltage >= resting, iterations)
+		}
+	})
+}
+
+func Test_CellType(t *testing.T) {
+	t.Run("synapses from typed cells get the sign of the type", func(t *testing.T) {
+		assert.Equal(t, int16(5), ExcitatoryCell.signed(-5))
+		assert.Equal(t, int16(-5), InhibitoryCell.signed(5))
+		assert.Equal(t, int16(-5), MixedCell.signed(-5))
+		assert.Equal(t, int16(0), InhibitoryCell.signed(0))
+	})
+	t.Run("is saved with the network", func(t *testing.T) {
+		network := NewNetwork()
+		cell := NewCell(network)
+		cell.Type = InhibitoryCell
+		err := network.SaveToFile("_network_celltype.test.json")
+		assert.NoError(t, err)
+		net2, err := LoadNetworkFromFile("_network_celltype.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, InhibitoryCell, net2.Cells[0].Type)
+		assert.Equal(t, "inhibitory", net2.Cells[0].Type.String())
+	})
+	t.Run("is kept when the network is cloned", func(t *testing.T) {
+		network := NewNetwork()
+		cell := NewCell(network)
+		cell.Type = ExcitatoryCell
+		clone := CloneNetwork(network)
+		assert.Equal(t, ExcitatoryCell, clone.Cells[0].Type)
+	})
 }
@@ -0,0 +1,147 @@
+package potential
+
+/*
+Compact renumbers the cells and synapses densely, reclaiming the `nil` slots
+left behind by `PruneCell` and `PruneSynapse`.
+
+Because IDs are slice indexes, pruning leaves holes in `Cells` and `Synapses`
+forever. Compacting moves every remaining cell and synapse down to the lowest
+free ID, keeping their order, and rewrites all the references to them - the
+synapse to/from cells, the cells' axon and dendrite maps, and anything queued
+to fire. Synapses to or from cells that do not exist are dropped, like pruned
+ones, rather than left pointing at some other cell.
+
+Returns maps of every remaining cell and synapse from their old ID to their new
+ID, including the ones that did not move. Anything holding onto IDs from
+before, like a Vocabulary, needs to be updated with them - see
+`Vocabulary.RemapCells`.
+*/
+func (network *Network) Compact() (cellIDs map[CellID]CellID, synapseIDs map[SynapseID]SynapseID) {
+	network.cellMux.Lock()
+	network.synMux.Lock()
+	defer network.cellMux.Unlock()
+	defer network.synMux.Unlock()
+
+	cellIDs = make(map[CellID]CellID)
+	synapseIDs = make(map[SynapseID]SynapseID)
+
+	cells := make([]*Cell, 0, len(network.Cells))
+	for _, cell := range network.Cells {
+		if cell == nil { // pruned
+			continue
+		}
+		newID := CellID(len(cells))
+		cellIDs[cell.ID] = newID
+		cell.ID = newID
+		cells = append(cells, cell)
+	}
+
+	synapses := make([]*Synapse, 0, len(network.Synapses))
+	for _, synapse := range network.Synapses {
+		if synapse == nil { // pruned
+			continue
+		}
+		from, fromOK := cellIDs[synapse.FromNeuronAxon]
+		to, toOK := cellIDs[synapse.ToNeuronDendrite]
+		if !fromOK || !toOK { // dangling, so pruned too
+			continue
+		}
+		newID := SynapseID(len(synapses))
+		synapseIDs[synapse.ID] = newID
+		synapse.ID = newID
+		synapse.FromNeuronAxon = from
+		synapse.ToNeuronDendrite = to
+		synapses = append(synapses, synapse)
+	}
+
+	for _, cell := range cells {
+		cell.AxonSynapses = remapSynapseSet(cell.AxonSynapses, synapseIDs)
+		cell.DendriteSynapses = remapSynapseSet(cell.DendriteSynapses, synapseIDs)
+	}
+
+	network.Cells = cells
+	network.Synapses = synapses
+
+	// transient firing state refers to IDs, too
+	network.nextSynapses = remapSynapseList(network.nextSynapses, synapseIDs)
+	for slot, pending := range network.pendingDeliveries {
+		network.pendingDeliveries[slot] = remapSynapseList(pending, synapseIDs)
+	}
+	activeCells := make(map[CellID]bool)
+	for cellID := range network.activeCells {
+		if newID, ok := cellIDs[cellID]; ok {
+			activeCells[newID] = true
+		}
+	}
+	network.activeCells = activeCells
+	var lastFired []CellID
+	for _, cellID := range network.lastFired {
+		if newID, ok := cellIDs[cellID]; ok {
+			lastFired = append(lastFired, newID)
+		}
+	}
+	network.lastFired = lastFired
+	network.regionCells = nil
+	network.plasticityRule().Reset()
+
+	return cellIDs, synapseIDs
+}
+
+// remapSynapseSet returns a new set with the new synapse IDs, dropping any
+// synapses that no longer exist.
+func remapSynapseSet(set map[SynapseID]bool, synapseIDs map[SynapseID]SynapseID) map[SynapseID]bool {
+	remapped := make(map[SynapseID]bool, len(set))
+	for synapseID := range set {
+		if newID, ok := synapseIDs[synapseID]; ok {
+			remapped[newID] = true
+		}
+	}
+	return remapped
+}
+
+// remapSynapseList is the same as remapSynapseSet, keeping the order of the list.
+func remapSynapseList(list []SynapseID, synapseIDs map[SynapseID]SynapseID) []SynapseID {
+	if list == nil {
+		return nil
+	}
+	remapped := make([]SynapseID, 0, len(list))
+	for _, synapseID := range list {
+		if newID, ok := synapseIDs[synapseID]; ok {
+			remapped = append(remapped, newID)
+		}
+	}
+	return remapped
+}
+
+/*
+RemapCells updates the cell IDs in the vocab's Inputs, Outputs, Noise and Readout after
+the network was compacted. `cellIDs` is the old to new map from
+`Network.Compact`.
+
+Like `rerouteChangedIOCellIDs`, but it builds new firing patterns instead of
+swapping in place, because compacting moves many cells onto IDs that other
+cells used to have. Cells missing from the map no longer exist, so they are
+dropped.
+*/
+func (vocab *Vocabulary) RemapCells(cellIDs map[CellID]CellID) {
+	for _, vocabUnit := range vocab.Inputs {
+		vocabUnit.InputCells = remapFiringPattern(vocabUnit.InputCells, cellIDs)
+	}
+	for _, outColl := range vocab.Outputs {
+		outColl.FirePattern = remapFiringPattern(outColl.FirePattern, cellIDs)
+	}
+	vocab.Noise = remapFiringPattern(vocab.Noise, cellIDs)
+	if vocab.Readout != nil {
+		vocab.Readout.remap(cellIDs)
+	}
+}
+
+func remapFiringPattern(fp FiringPattern, cellIDs map[CellID]CellID) FiringPattern {
+	remapped := make(FiringPattern)
+	for cellID, fires := range fp {
+		if newID, ok := cellIDs[cellID]; ok {
+			remapped[newID] = fires
+		}
+	}
+	return remapped
+}
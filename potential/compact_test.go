@@ -0,0 +1,116 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Compact(t *testing.T) {
+	// a ring of a -> b -> c -> d -> a
+	var network *Network
+	var a, b, c, d *Cell
+	var ab, bc, cd, da *Synapse
+	before := func() {
+		network = NewNetwork()
+		a = NewCell(network)
+		b = NewCell(network)
+		c = NewCell(network)
+		d = NewCell(network)
+		ab = network.linkCells(a.ID, b.ID)
+		bc = network.linkCells(b.ID, c.ID)
+		cd = network.linkCells(c.ID, d.ID)
+		da = network.linkCells(d.ID, a.ID)
+	}
+
+	t.Run("removes pruned holes and renumbers densely", func(t *testing.T) {
+		before()
+		network.PruneSynapse(ab.ID)
+		network.PruneSynapse(bc.ID) // b has no synapses left, so it is pruned
+		assert.Nil(t, network.Cells[b.ID])
+
+		cellIDs, synapseIDs := network.Compact()
+
+		assert.Equal(t, 3, len(network.Cells))
+		assert.Equal(t, 2, len(network.Synapses))
+		for i, cell := range network.Cells {
+			assert.Equal(t, CellID(i), cell.ID)
+		}
+		for i, synapse := range network.Synapses {
+			assert.Equal(t, SynapseID(i), synapse.ID)
+		}
+		assert.Equal(t, map[CellID]CellID{0: 0, 2: 1, 3: 2}, cellIDs)
+		assert.Equal(t, map[SynapseID]SynapseID{2: 0, 3: 1}, synapseIDs)
+
+		ok, report := CheckIntegrity(network)
+		assert.True(t, ok, report)
+	})
+
+	t.Run("rewrites the synapse references on cells and cells on synapses", func(t *testing.T) {
+		before()
+		network.PruneSynapse(ab.ID)
+		network.PruneSynapse(bc.ID)
+		network.Compact()
+
+		// c -> d is now 0: 1 -> 2
+		assert.Equal(t, SynapseID(0), cd.ID)
+		assert.Equal(t, CellID(1), cd.FromNeuronAxon)
+		assert.Equal(t, CellID(2), cd.ToNeuronDendrite)
+		assert.Equal(t, map[SynapseID]bool{0: true}, c.AxonSynapses)
+		assert.Equal(t, map[SynapseID]bool{0: true}, d.DendriteSynapses)
+		// d -> a is now 1: 2 -> 0
+		assert.Equal(t, SynapseID(1), da.ID)
+		assert.Equal(t, map[SynapseID]bool{1: true}, d.AxonSynapses)
+		assert.Equal(t, map[SynapseID]bool{1: true}, a.DendriteSynapses)
+	})
+
+	t.Run("drops synapses to or from cells that do not exist", func(t *testing.T) {
+		before()
+		network.Cells[b.ID] = nil // gone without pruning a -> b and b -> c
+
+		_, synapseIDs := network.Compact()
+
+		assert.Equal(t, map[SynapseID]SynapseID{2: 0, 3: 1}, synapseIDs)
+		assert.Equal(t, 2, len(network.Synapses))
+		assert.Empty(t, a.AxonSynapses)
+		assert.Empty(t, c.DendriteSynapses)
+		ok, report := CheckIntegrity(network)
+		assert.True(t, ok, report)
+	})
+
+	t.Run("does nothing to a network without holes", func(t *testing.T) {
+		before()
+		network.Compact()
+		assert.Equal(t, 4, len(network.Cells))
+		assert.Equal(t, 4, len(network.Synapses))
+		assert.Equal(t, CellID(2), c.ID)
+		assert.Equal(t, SynapseID(1), bc.ID)
+	})
+
+	t.Run("queued synapses still fire after compacting", func(t *testing.T) {
+		before()
+		cd.Millivolts = network.Laws.ActualSynapseMax()
+		network.PruneSynapse(ab.ID)
+		network.PruneSynapse(bc.ID)
+		c.FireActionPotential()
+		network.Compact()
+		network.Step()
+		assert.Equal(t, []CellID{d.ID}, network.FiredLastStep())
+	})
+}
+
+func Test_VocabRemapCells(t *testing.T) {
+	t.Run("moves inputs, outputs and noise to the new IDs and drops removed cells", func(t *testing.T) {
+		vocab := NewVocabulary(NewNetwork())
+		vocab.Inputs["a"] = &VocabUnit{Value: "a", InputCells: FiringPattern{1: 1, 3: 1}}
+		vocab.Outputs["b"] = &OutputCollection{Value: "b", FirePattern: FiringPattern{3: 4, 5: 2}}
+		vocab.Noise = FiringPattern{5: 1}
+
+		// 3 and 5 move onto IDs that used to belong to other cells, 1 was removed
+		vocab.RemapCells(map[CellID]CellID{3: 1, 5: 3})
+
+		assert.Equal(t, FiringPattern{1: 1}, vocab.Inputs["a"].InputCells)
+		assert.Equal(t, FiringPattern{1: 4, 3: 2}, vocab.Outputs["b"].FirePattern)
+		assert.Equal(t, FiringPattern{3: 1}, vocab.Noise)
+	})
+}
@@ -3,8 +3,6 @@ package potential
 import (
 	"math"
 	"strconv"
-
-	"github.com/ruffrey/nurtrace/laws"
 )
 
 /*
@@ -44,8 +42,6 @@ func findDupeSynapses(network *Network) map[string]dupeSynapses {
 	return dupes
 }
 
-const _actualSynapseMaxFloat64 = float64(laws.ActualSynapseMax)
-
 /*
 dedupeSynapses receives a list of synapses that are known to have
 the same inputs and outputs, removing as many as possible
@@ -60,15 +56,15 @@ func dedupeSynapses(synapses dupeSynapses, network *Network) []SynapseID {
 		sum += float64(network.GetSyn(synapseID).Millivolts) // unlikely to overflow, but may
 	}
 
-	keepTotal := int(math.Ceil(math.Abs(sum) / _actualSynapseMaxFloat64))
+	keepTotal := int(math.Ceil(math.Abs(sum) / float64(network.Laws.ActualSynapseMax())))
 	isPositive := sum >= 0
 	keepSynapses = synapses[0:keepTotal]
 
 	var max int16
 	if isPositive {
-		max = laws.ActualSynapseMax
+		max = network.Laws.ActualSynapseMax()
 	} else {
-		max = laws.ActualSynapseMin
+		max = network.Laws.ActualSynapseMin()
 	}
 
 	if keepTotal == dupeSynapsesTotal {
@@ -2,6 +2,8 @@ package potential
 
 import (
 	"log"
+	"sort"
+	"strconv"
 )
 
 /*
@@ -11,6 +13,7 @@ Diff values are always *old minus new*. They can be positive or negative. The di
 added back later when needed.
 */
 type Diff struct {
+	// NetworkVersion is the schema version of the newer network.
 	NetworkVersion string
 	/*
 	   synapses is a map where the keys are synapse IDs, and the value is the difference between
@@ -20,6 +23,8 @@ type Diff struct {
 	synapseFires  map[SynapseID]uint
 	addedSynapses []*Synapse
 	addedCells    map[CellID]*Cell
+	// synapses that were pruned on the newer network, such as by weakening
+	removedSynapses []SynapseID
 }
 
 /*
@@ -37,6 +42,7 @@ func (diff *Diff) Print() {
 	for _, c := range diff.addedCells {
 		log.Println("    ", c.ID)
 	}
+	log.Println("  removedSynapses", diff.removedSynapses)
 }
 
 /*
@@ -66,6 +72,7 @@ so that's fine. A cell is really just a collection of synapses.
 */
 func DiffNetworks(originalNetwork, newerNetwork *Network) (diff Diff) {
 	diff = NewDiff()
+	diff.NetworkVersion = strconv.Itoa(newerNetwork.SchemaVersion)
 
 	// Get new synapses and the millivolt differences between existing synapses
 	for id, newerNetworkSynapse := range newerNetwork.Synapses {
@@ -76,6 +83,11 @@ func DiffNetworks(originalNetwork, newerNetwork *Network) (diff Diff) {
 			}
 			continue
 		}
+		if newerNetworkSynapse == nil {
+			// pruned on the newer network
+			diff.removedSynapses = append(diff.removedSynapses, SynapseID(id))
+			continue
+		}
 		originalSynapse := originalNetwork.GetSyn(SynapseID(id))
 		// the syanpse ID is not unique on the original network.
 		// we need to make sure we didn't happen to generate it in a collision, though.
@@ -105,7 +117,10 @@ func DiffNetworks(originalNetwork, newerNetwork *Network) (diff Diff) {
 	// Get new cells that were added to the network
 	for id, newerNetworkCell := range newerNetwork.Cells {
 		alreadyExisted := originalNetwork.CellExists(CellID(id))
-		if !alreadyExisted {
+		// pruned on the original after the newer network was cloned, such as
+		// by Sleep, so it is not new
+		prunedOnOriginal := id < len(originalNetwork.Cells) && !alreadyExisted
+		if !alreadyExisted && !prunedOnOriginal && newerNetworkCell != nil {
 			//log.Println("Diff: new cell", id)
 			diff.addedCells[CellID(id)] = newerNetworkCell
 		}
@@ -135,8 +150,17 @@ Returns a map of any cells that had their IDs change. `map[fromCellID]toCellID`
 */
 func ApplyDiff(diff Diff, originalNetwork *Network) (reIDedCells map[CellID]CellID) {
 	reIDedCells = make(map[CellID]CellID) // old ID:newID
-	// New cells
-	for _, cell := range diff.addedCells {
+	if version := strconv.Itoa(originalNetwork.SchemaVersion); diff.NetworkVersion != "" && diff.NetworkVersion != version {
+		log.Println("Warning: applying a diff from schema version", diff.NetworkVersion, "to a network at", version)
+	}
+	// New cells, in order so the new IDs are the same every time
+	addedCellIDs := make([]CellID, 0, len(diff.addedCells))
+	for cellID := range diff.addedCells {
+		addedCellIDs = append(addedCellIDs, cellID)
+	}
+	sort.Slice(addedCellIDs, func(i, j int) bool { return addedCellIDs[i] < addedCellIDs[j] })
+	for _, cellID := range addedCellIDs {
+		cell := diff.addedCells[cellID]
 		newCellID := copyCellToNetwork(cell, originalNetwork)
 		if cell.ID != newCellID {
 			reIDedCells[cell.ID] = newCellID
@@ -145,6 +169,14 @@ func ApplyDiff(diff Diff, originalNetwork *Network) (reIDedCells map[CellID]Cell
 
 	// New synapses
 	for _, synapse := range diff.addedSynapses {
+		// skip synapses onto cells that were pruned on the original since
+		// the newer network was cloned
+		_, newAxonCell := diff.addedCells[synapse.FromNeuronAxon]
+		_, newDendriteCell := diff.addedCells[synapse.ToNeuronDendrite]
+		if (!newAxonCell && !originalNetwork.CellExists(synapse.FromNeuronAxon)) ||
+			(!newDendriteCell && !originalNetwork.CellExists(synapse.ToNeuronDendrite)) {
+			continue
+		}
 
 		// If this synapse was attached to a new cell, we need
 		// to delete that cell's old synapse reference because the cell
@@ -163,7 +195,7 @@ func ApplyDiff(diff Diff, originalNetwork *Network) (reIDedCells map[CellID]Cell
 		}
 
 		// old dendrite connection removed from cell if cell is new
-		if _, isNewCell := diff.addedCells[synapse.ToNeuronDendrite]; isNewCell && !notSameID {
+		if _, isNewCell := diff.addedCells[synapse.ToNeuronDendrite]; isNewCell && notSameID {
 			// log.Println("  removing old synapse reference from new cell (dendrite)", synapse.ToNeuronDendrite)
 
 			delete(originalNetwork.Cells[synapse.ToNeuronDendrite].DendriteSynapses, synapse.ID)
@@ -181,6 +213,14 @@ func ApplyDiff(diff Diff, originalNetwork *Network) (reIDedCells map[CellID]Cell
 		s.ActivationHistory += activations
 	}
 
+	// Prune last, so new synapses are already attached to the cells that
+	// might otherwise have been left empty and pruned.
+	for _, synapseID := range diff.removedSynapses {
+		if originalNetwork.SynExists(synapseID) {
+			originalNetwork.PruneSynapse(synapseID)
+		}
+	}
+
 	return reIDedCells
 }
 
@@ -188,15 +228,31 @@ func ApplyDiff(diff Diff, originalNetwork *Network) (reIDedCells map[CellID]Cell
 CloneNetwork returns an exact copy of a network - not a pointer. This is useful when
 doing distributed training.
 
-It involves resetting pointers.
+It involves resetting pointers. Synapse ActivationHistory starts over at zero on the
+copy.
 */
 func CloneNetwork(originalNetwork *Network) *Network {
 	newNetwork := NewNetwork()
+	newNetwork.Laws = originalNetwork.Laws.Clone()
+	// the copy gets its own generator, seeded from the original so it is
+	// still repeatable
+	newNetwork.SetSeed(originalNetwork.rng.Int63())
+	for name, region := range originalNetwork.Regions {
+		copied := newNetwork.AddRegion(name)
+		for to, weight := range region.Connections {
+			copied.Connections[to] = weight
+		}
+	}
 
 	originalNetwork.cellMux.Lock()
 	for _, cell := range originalNetwork.Cells {
 		originalNetwork.cellMux.Unlock()
-		copyCellToNetwork(cell, newNetwork)
+		if cell == nil { // pruned
+			// preserve the cell order, same as synapses below
+			newNetwork.Cells = append(newNetwork.Cells, nil)
+		} else {
+			copyCellToNetwork(cell, newNetwork)
+		}
 		originalNetwork.cellMux.Lock()
 	}
 	originalNetwork.cellMux.Unlock()
@@ -214,6 +270,14 @@ func CloneNetwork(originalNetwork *Network) *Network {
 	}
 	originalNetwork.synMux.Unlock()
 
+	// The copy only counts its own activations, so the diff adds just the new
+	// ones back onto the original.
+	for _, synapse := range newNetwork.Synapses {
+		if synapse != nil {
+			synapse.ActivationHistory = 0
+		}
+	}
+
 	return newNetwork
 }
 
@@ -229,8 +293,14 @@ func copyCellToNetwork(origCell *Cell, newNetwork *Network) CellID {
 	copiedCell.Network = newNetwork
 
 	copiedCell.Immortal = origCell.Immortal
+	copiedCell.Type = origCell.Type
 	copiedCell.activating = origCell.activating
+	copiedCell.refractoryLeft = origCell.refractoryLeft
+	copiedCell.Threshold = origCell.Threshold
+	copiedCell.RefractorySteps = origCell.RefractorySteps
 	copiedCell.Voltage = origCell.Voltage
+	copiedCell.Region = origCell.Region
+	newNetwork.markActive(copiedCell)
 
 	// golang does not copy a map on assignment; must loop over it.
 
@@ -267,6 +337,7 @@ func copySynapseToNetwork(synapse *Synapse, newNetwork *Network) SynapseID {
 	copiedSynapse.ToNeuronDendrite = synapse.ToNeuronDendrite
 
 	copiedSynapse.Millivolts = synapse.Millivolts
+	copiedSynapse.Delay = synapse.Delay
 	// we do need to keep this property because we will want to
 	// grow/prune the synapse later
 	copiedSynapse.ActivationHistory = synapse.ActivationHistory
@@ -175,6 +175,45 @@ func Test_ApplyDiff(t *testing.T) {
 	})
 }
 
+func Test_ApplyDiffPrunedSynapses(t *testing.T) {
+	t.Run("a network with pruned cells can be cloned", func(t *testing.T) {
+		original := NewNetwork()
+		a := NewCell(original)
+		b := NewCell(original)
+		s := original.linkCells(a.ID, b.ID)
+		original.linkCells(b.ID, NewCell(original).ID)
+		original.PruneSynapse(s.ID)
+		assert.Nil(t, original.Cells[a.ID])
+
+		cloned := CloneNetwork(original)
+		assert.Equal(t, len(original.Cells), len(cloned.Cells))
+		assert.Nil(t, cloned.Cells[a.ID])
+		ok, report := CheckIntegrity(cloned)
+		assert.True(t, ok, report)
+	})
+	t.Run("synapses pruned on the newer network are pruned on the original", func(t *testing.T) {
+		original := NewNetwork()
+		a := NewCell(original)
+		b := NewCell(original)
+		c := NewCell(original)
+		ab := original.linkCells(a.ID, b.ID)
+		bc := original.linkCells(b.ID, c.ID)
+
+		cloned := CloneNetwork(original)
+		cloned.PruneSynapse(ab.ID)
+
+		diff := DiffNetworks(original, cloned)
+		assert.Equal(t, []SynapseID{ab.ID}, diff.removedSynapses)
+		ApplyDiff(diff, original)
+
+		assert.False(t, original.SynExists(ab.ID))
+		assert.True(t, original.SynExists(bc.ID))
+		assert.False(t, original.CellExists(a.ID))
+		ok, report := CheckIntegrity(original)
+		assert.True(t, ok, report)
+	})
+}
+
 func Test_ApplyDiff_TrickeryIntegrityTests(t *testing.T) {
 	t.Run("a cell ID is new and all synapses get reassigned, keeping network integrity", func(t *testing.T) {
 		// network 1
@@ -225,6 +264,26 @@ func Test_ApplyDiff_TrickeryIntegrityTests(t *testing.T) {
 		ok, report = CheckIntegrity(network)
 		assert.Equal(t, true, ok, "no integrity after apply diff")
 	})
+	t.Run("a new cell keeps a dendrite synapse whose ID did not change", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		network.linkCells(a.ID, b.ID)
+
+		net2 := CloneNetwork(network)
+		c := NewCell(net2)
+		ac := net2.linkCells(a.ID, c.ID)
+
+		diff := DiffNetworks(network, net2)
+		ApplyDiff(diff, network)
+
+		// nothing collided, so the cell and synapse kept their IDs
+		assert.True(t, network.SynExists(ac.ID))
+		assert.Equal(t, c.ID, network.GetSyn(ac.ID).ToNeuronDendrite)
+		assert.True(t, network.GetCell(c.ID).DendriteSynapses[ac.ID])
+		ok, _ := CheckIntegrity(network)
+		assert.True(t, ok)
+	})
 	t.Run("when a cell is new and another already exists one of its synapses was new", func(t *testing.T) {
 		t.Run("the old synapse ID is removed from the dendrite synapse list", func(t *testing.T) {
 			network := NewNetwork()
@@ -3,8 +3,7 @@ package potential
 import (
 	"log"
 	"math"
-
-	"github.com/ruffrey/nurtrace/laws"
+	"sort"
 )
 
 /*
@@ -31,7 +30,7 @@ func cloneFiringPattern(fp FiringPattern) FiringPattern {
 /*
 FireNetworkUntilDone takes some seed cells, fires them,
 then fires the network until it has no more firing - up
-to `laws.MaxPostFireSteps`.
+to the network's `MaxPostFireSteps` law.
 
 Consider that you may want to ResetForTraining before running this.
 */
@@ -39,24 +38,33 @@ func FireNetworkUntilDone(network *Network, seedCells FiringPattern) FiringPatte
 	i := 0
 	fp := make(FiringPattern)
 
-	// Do the seeding
+	// Do the seeding, in order because learning rules may care which cell
+	// spikes first
+	seedIDs := make([]CellID, 0, len(seedCells))
+	for cellID := range seedCells {
+		seedIDs = append(seedIDs, cellID)
+	}
+	sort.Slice(seedIDs, func(i, j int) bool { return seedIDs[i] < seedIDs[j] })
+
 	network.FireNoise()
-	for ; i < laws.FiringIterationsPerSample; i++ {
-		for cellID := range seedCells {
-			network.GetCell(cellID).FireActionPotential()
+	for ; i < network.Laws.FiringIterationsPerSample; i++ {
+		for _, cellID := range seedIDs {
+			// learning can prune a seed cell that lost all its synapses
+			if network.CellExists(cellID) {
+				network.GetCell(cellID).FireActionPotential()
+			}
 		}
 		network.Step()
 	}
 
 	i = 0
 	for {
-		if i >= laws.MaxPostFireSteps {
+		if i >= network.Laws.MaxPostFireSteps {
 			break
 		}
 
 		hasMore := network.Step()
-		for _cellID, cell := range network.Cells {
-			cellID := CellID(_cellID)
+		for _, cellID := range network.FiredLastStep() {
 			// skip seed cells on first round because they will
 			// still be activating
 			if i == 0 {
@@ -64,12 +72,10 @@ func FireNetworkUntilDone(network *Network, seedCells FiringPattern) FiringPatte
 					continue
 				}
 			}
-			if cell.activating {
-				if _, ok := fp[cellID]; !ok {
-					fp[cellID] = 0
-				}
-				fp[cellID]++
+			if _, ok := fp[cellID]; !ok {
+				fp[cellID] = 0
 			}
+			fp[cellID]++
 		}
 
 		if !hasMore {
@@ -288,13 +294,13 @@ func RunFiringPatternTraining(vocab *Vocabulary, chSynchVocab chan *Vocabulary,
 			expandInputs(vocab, cellsToFireForInputValues)
 			newPattern = sampleFirePattern
 			// actual pattern gets expanded for more uniqueness
-			expandOutputs(vocab.Net, closestOutput.FirePattern, 1-laws.PatternSimilarityLimit)
+			expandOutputs(vocab.Net, closestOutput.FirePattern, 1-vocab.Net.Laws.PatternSimilarityLimit)
 		}
 
 		vocab.Outputs[s.output].FirePattern = newPattern
 
 		// sample is finished here, but provide an update on progress
-		shouldRecalibrate = sampleIndex%laws.TrainingMergeBackIteration == 0
+		shouldRecalibrate = sampleIndex%vocab.Net.Laws.TrainingMergeBackIteration == 0
 		if shouldRecalibrate {
 			if sampleIndex != 0 { // not the first time
 				chSynchVocab <- vocab
@@ -324,9 +330,10 @@ func (vocab *Vocabulary) CheckAndReduceSimilarity() {
 
 	//outputsUnique := make(map[OutputValue]map[CellID]bool)
 
-	for _, primary := range vocab.Outputs {
+	outputs := vocab.sortedOutputs()
+	for _, primary := range outputs {
 		vocab.Net.ResetForTraining()
-		for _, secondary := range vocab.Outputs {
+		for _, secondary := range outputs {
 			isThisOne := secondary.Value == primary.Value
 			if isThisOne {
 				continue
@@ -349,7 +356,7 @@ func (vocab *Vocabulary) CheckAndReduceSimilarity() {
 			// two patterns
 			diff := DiffFiringPatterns(primary.FirePattern, secondary.FirePattern)
 			ratio, unsharedFiringPattern := diff.SimilarityRatio()
-			tooSimilar := ratio > laws.PatternSimilarityLimit
+			tooSimilar := ratio > vocab.Net.Laws.PatternSimilarityLimit
 			if tooSimilar {
 				// change this output pattern
 				//log.Println("EXPAND:", secondary.Value, "vs", primary.Value, "is", ratio)
@@ -390,7 +397,7 @@ Subtracting noise is done.
 func FindClosestOutputCollection(patt FiringPattern, vocab *Vocabulary) (oc *OutputCollection) {
 	closestRatio := 0.0
 	slimPatt := removeNoise(vocab.Noise, patt)
-	for _, outputCandidate := range vocab.Outputs {
+	for _, outputCandidate := range vocab.sortedOutputs() {
 		noiselessPattern := removeNoise(vocab.Noise, outputCandidate.FirePattern)
 		r, _ := DiffFiringPatterns(slimPatt, noiselessPattern).SimilarityRatio()
 		isCloser := r > closestRatio
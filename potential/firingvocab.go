@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 )
 
 /*
@@ -26,19 +29,59 @@ type Vocabulary struct {
 	Threads    int
 	Noise      FiringPattern
 	Workerfile string
+	/*
+		SleepEvery is how many merges Train does between sleep phases. Zero
+		means it never sleeps. See `Network.Sleep`.
+	*/
+	SleepEvery int
+	/*
+		Readout decodes what the network fired into an output, instead of the
+		closest OutputCollection, when it is set. See TrainReadout.
+	*/
+	Readout *Readout `json:",omitempty"`
+	/*
+		InputRegion is the sensory region that new inputs choose their cells
+		from. Empty means any cell in the network.
+	*/
+	InputRegion string
+	/*
+		Seed is what the vocab's random number generator was last seeded with.
+		It is used for training bookkeeping, not the network.
+	*/
+	Seed int64
+	rng  *rand.Rand
+	/*
+		SchemaVersion is the version of the fields the vocab was saved with.
+		Loading upgrades older vocabs to CurrentSchemaVersion.
+	*/
+	SchemaVersion int
 }
 
 /*
-NewVocabulary is a factory
+NewVocabulary is a factory. The random number generator is seeded from the
+current time; use SetSeed for a repeatable vocab.
 */
 func NewVocabulary(network *Network) *Vocabulary {
-	return &Vocabulary{
+	vocab := &Vocabulary{
 		Net:     network,
 		Inputs:  make(map[InputValue]*VocabUnit),
 		Outputs: make(map[OutputValue]*OutputCollection),
 		Threads: runtime.NumCPU(),
 		Noise:   make(FiringPattern),
+
+		SchemaVersion: CurrentSchemaVersion,
 	}
+	vocab.SetSeed(time.Now().UnixNano())
+	return vocab
+}
+
+/*
+SetSeed resets the vocab's random number generator, and records the seed so
+it is saved with the vocab.
+*/
+func (vocab *Vocabulary) SetSeed(seed int64) {
+	vocab.Seed = seed
+	vocab.rng = rand.New(rand.NewSource(seed))
 }
 
 // sample is a training data sample
@@ -54,6 +97,23 @@ func (vocab *Vocabulary) ClearSamples() {
 	vocab.Samples = make([]sample, 0)
 }
 
+/*
+sortedOutputs returns the output collections ordered by value, so walking them
+does not depend on Go's random map ordering.
+*/
+func (vocab *Vocabulary) sortedOutputs() []*OutputCollection {
+	values := make([]string, 0, len(vocab.Outputs))
+	for value := range vocab.Outputs {
+		values = append(values, string(value))
+	}
+	sort.Strings(values)
+	outputs := make([]*OutputCollection, len(values))
+	for i, value := range values {
+		outputs[i] = vocab.Outputs[OutputValue(value)]
+	}
+	return outputs
+}
+
 /*
 CloneOutputs returns a new grouping of NEW OutputCollections (not shared
 pointers).
@@ -136,12 +196,36 @@ LoadVocabFromFile loads the vocab from a JSON file but does not populate
 the Net (the network).
 */
 func LoadVocabFromFile(filepath string) (vocab *Vocabulary, err error) {
+	vocab, report, err := loadVocabFromFile(filepath)
+	if err == nil && report.Migrated() {
+		log.Println("Upgraded vocab", filepath, "from schema version", report.From, "to", report.To,
+			"- use `nt migrate` to save it that way")
+	}
+	return vocab, err
+}
+
+/*
+loadVocabFromFile is LoadVocabFromFile, also reporting how the vocab was
+migrated.
+*/
+func loadVocabFromFile(filepath string) (vocab *Vocabulary, report *MigrationReport, err error) {
 	bytes, err := ioutil.ReadFile(filepath)
 	if err != nil {
-		return vocab, err
+		return vocab, report, err
 	}
-	err = json.Unmarshal(bytes, &vocab)
-	return vocab, err
+	bytes, report, err = MigrateVocabJSON(bytes)
+	if err != nil {
+		return nil, report, fmt.Errorf("Cannot load vocab from file %s: %s", filepath, err)
+	}
+	report.Format = "json"
+	vocab = NewVocabulary(nil)
+	err = json.Unmarshal(bytes, vocab)
+	if err != nil {
+		return nil, report, err
+	}
+	// vocabs saved without a seed keep the one from NewVocabulary
+	vocab.SetSeed(vocab.Seed)
+	return vocab, report, err
 }
 
 /*
@@ -2,8 +2,7 @@ package potential
 
 import (
 	"math"
-
-	"github.com/ruffrey/nurtrace/laws"
+	"sort"
 )
 
 // InputValue is a unique string for the input
@@ -53,14 +52,20 @@ func NewOutputCollection(value OutputValue) *OutputCollection {
 }
 
 /*
-InitRandomInputs chooses some input cells for the vocab unit.
+InitRandomInputs chooses some input cells for the vocab unit, from the vocab's
+InputRegion when it has one.
 */
 func (vu *VocabUnit) InitRandomInputs(vocab *Vocabulary) {
-	for i := 0; i < laws.InitialCellCountPerInput; i++ {
+	for i := 0; i < vocab.Net.Laws.InitialCellCountPerInput; i++ {
 		var inputCellID CellID
 		var depthGrowerCellID CellID // attempt to ensure there are deep paths in the net
-		for {
-			inputCellID = vocab.Net.RandomCellKey()
+		for tries := 0; ; tries++ {
+			// a full sensory region spills over onto the rest of the network
+			if vocab.InputRegion != "" && tries < 100 {
+				inputCellID = vocab.Net.randomCellInRegion(vocab.InputRegion)
+			} else {
+				inputCellID = vocab.Net.RandomCellKey()
+			}
 			if !isCellOnAnyInput(inputCellID, vocab.Inputs) {
 				break
 			}
@@ -72,7 +77,7 @@ func (vu *VocabUnit) InitRandomInputs(vocab *Vocabulary) {
 			}
 		}
 		vu.InputCells[inputCellID] = 1
-		vocab.Net.GrowPathBetween(inputCellID, depthGrowerCellID, laws.ComputedSynapsesPerCell)
+		vocab.Net.GrowPathBetween(inputCellID, depthGrowerCellID, vocab.Net.Laws.ComputedSynapsesPerCell())
 	}
 }
 
@@ -85,17 +90,13 @@ func isCellOnAnyInput(cellID CellID, inputs map[InputValue]*VocabUnit) bool {
 	return false
 }
 
-func randCellFromFP(cellMap FiringPattern) (randCellID CellID) {
-	iterate := randomIntBetween(0, len(cellMap)-1)
-	i := 0
+func (network *Network) randCellFromFP(cellMap FiringPattern) (randCellID CellID) {
+	keys := make([]CellID, 0, len(cellMap))
 	for k := range cellMap {
-		if i == iterate {
-			randCellID = CellID(k)
-			break
-		}
-		i++
+		keys = append(keys, k)
 	}
-	return randCellID
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys[network.randomIntBetween(0, len(keys)-1)]
 }
 
 /*
@@ -104,8 +105,8 @@ but not by increasing the number of input cells - just adding cells that
 connect directly to input cells.
 */
 func expandInputs(vocab *Vocabulary, fp FiringPattern) {
-	for i := 0; i < laws.InputCellDifferentiationCount; i++ {
-		preCell := randCellFromFP(fp)
+	for i := 0; i < vocab.Net.Laws.InputCellDifferentiationCount; i++ {
+		preCell := vocab.Net.randCellFromFP(fp)
 		// Do not just fire another input cell; that would
 		// be a little confounding right out of the gate.
 		for {
@@ -119,23 +120,23 @@ func expandInputs(vocab *Vocabulary, fp FiringPattern) {
 	}
 }
 
-// Not greater than 10% more synapses than the ideal number of synapses per cell.
-const _maxSynapseRatio float64 = laws.IdealCellSynapseBalance - (laws.IdealCellSynapseBalance * .1)
-
 /*
 expandOutputs expands an output firing pattern by adding more
 synapses from the firting pattern to random cells.
 */
 func expandOutputs(network *Network, unsharedCellsFP FiringPattern, similarity float64) {
 	totalUnshared := float64(len(unsharedCellsFP))
-	pctOverLimit := similarity - laws.PatternSimilarityLimit
+	pctOverLimit := similarity - network.Laws.PatternSimilarityLimit
 	uniquenessToAdd := int(math.Ceil(pctOverLimit * totalUnshared))
 
+	idealBalance := network.Laws.IdealCellSynapseBalance
+	// Not greater than 10% more synapses than the ideal number of synapses per cell.
+	maxSynapseRatio := idealBalance - (idealBalance * .1)
 	currentRatio := float64(len(network.Cells)) / float64(len(network.Synapses))
-	shouldAddCell := currentRatio > laws.IdealCellSynapseBalance || currentRatio < _maxSynapseRatio
+	shouldAddCell := currentRatio > idealBalance || currentRatio < maxSynapseRatio
 
 	for i := 0; i < uniquenessToAdd; i++ {
-		preCell := randCellFromFP(unsharedCellsFP)
+		preCell := network.randCellFromFP(unsharedCellsFP)
 		var nextCell CellID
 
 		if shouldAddCell {
@@ -144,6 +145,6 @@ func expandOutputs(network *Network, unsharedCellsFP FiringPattern, similarity f
 			nextCell = network.RandomCellKey()
 		}
 
-		network.GrowPathBetween(preCell, nextCell, laws.ComputedSynapsesPerCell)
+		network.GrowPathBetween(preCell, nextCell, network.Laws.ComputedSynapsesPerCell())
 	}
 }
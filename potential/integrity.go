@@ -1,23 +1,69 @@
 package potential
 
-import "log"
+import (
+	"encoding/json"
+	"log"
+)
 
 /*
 IntegrityReport lists all bad connections in a network that was tested.
+
+Errors make the network unsafe to use, and LoadNetworkFromFile refuses it. Repair
+fixes them. Warnings are allowed, but are usually a sign that something went
+wrong while growing or pruning.
 */
 type IntegrityReport struct {
-	cellHasMissingAxonSynapse     map[CellID]SynapseID
-	cellHasMissingDendriteSynapse map[CellID]SynapseID
+	// errors
+
+	// the ID of the cell or synapse is not its place in the network
+	cellIDMismatch    map[int]CellID
+	synapseIDMismatch map[int]SynapseID
+	// the cell lists a synapse that does not exist
+	cellHasMissingAxonSynapse     map[CellID][]SynapseID
+	cellHasMissingDendriteSynapse map[CellID][]SynapseID
+	// the cell lists a synapse that exists, but connects to a different cell
+	cellHasForeignAxonSynapse     map[CellID][]SynapseID
+	cellHasForeignDendriteSynapse map[CellID][]SynapseID
+	// the synapse connects to a cell that does not exist
 	synapseHasMissingDendriteCell map[SynapseID]CellID
 	synapseHasMissingAxonCell     map[SynapseID]CellID
+	// the synapse's cell does not list it
+	synapseNotOnAxonCell     map[SynapseID]CellID
+	synapseNotOnDendriteCell map[SynapseID]CellID
+	// the sign of the synapse is not allowed by the type of its axon cell
+	synapseSignDisagreesWithCell map[SynapseID]CellID
+
+	// warnings
+
+	// the synapse's millivolts are past the laws' ActualSynapseMin or Max, which
+	// narrow when SynapseLearnRate is raised on a trained network
+	synapseMillivoltsOutOfRange map[SynapseID]int16
+
+	// the synapse goes from a cell back to the same cell
+	selfLoopSynapses []SynapseID
+	// the synapse connects the same two cells, the same way, as an earlier one
+	duplicateSynapses map[SynapseID]SynapseID
+	// the cell has no synapses and is not immortal, so it should be pruned
+	orphanCells []CellID
 }
 
 func newIntegrityReport() IntegrityReport {
 	return IntegrityReport{
-		cellHasMissingAxonSynapse:     make(map[CellID]SynapseID),
-		cellHasMissingDendriteSynapse: make(map[CellID]SynapseID),
+		cellIDMismatch:                make(map[int]CellID),
+		synapseIDMismatch:             make(map[int]SynapseID),
+		cellHasMissingAxonSynapse:     make(map[CellID][]SynapseID),
+		cellHasMissingDendriteSynapse: make(map[CellID][]SynapseID),
+		cellHasForeignAxonSynapse:     make(map[CellID][]SynapseID),
+		cellHasForeignDendriteSynapse: make(map[CellID][]SynapseID),
 		synapseHasMissingDendriteCell: make(map[SynapseID]CellID),
 		synapseHasMissingAxonCell:     make(map[SynapseID]CellID),
+		synapseNotOnAxonCell:          make(map[SynapseID]CellID),
+		synapseNotOnDendriteCell:      make(map[SynapseID]CellID),
+		synapseSignDisagreesWithCell:  make(map[SynapseID]CellID),
+		synapseMillivoltsOutOfRange:   make(map[SynapseID]int16),
+		selfLoopSynapses:              make([]SynapseID, 0),
+		duplicateSynapses:             make(map[SynapseID]SynapseID),
+		orphanCells:                   make([]CellID, 0),
 	}
 }
 
@@ -25,48 +71,168 @@ func newIntegrityReport() IntegrityReport {
 Print outputs the contents of the report to stdout
 */
 func (report *IntegrityReport) Print() {
+	log.Println("cellIDMismatch", report.cellIDMismatch)
+	log.Println("synapseIDMismatch", report.synapseIDMismatch)
 	log.Println("cellHasMissingAxonSynapse", report.cellHasMissingAxonSynapse)
 	log.Println("cellHasMissingDendriteSynapse", report.cellHasMissingDendriteSynapse)
+	log.Println("cellHasForeignAxonSynapse", report.cellHasForeignAxonSynapse)
+	log.Println("cellHasForeignDendriteSynapse", report.cellHasForeignDendriteSynapse)
 	log.Println("synapseHasMissingDendriteCell", report.synapseHasMissingDendriteCell)
 	log.Println("synapseHasMissingAxonCell", report.synapseHasMissingAxonCell)
+	log.Println("synapseNotOnAxonCell", report.synapseNotOnAxonCell)
+	log.Println("synapseNotOnDendriteCell", report.synapseNotOnDendriteCell)
+	log.Println("synapseSignDisagreesWithCell", report.synapseSignDisagreesWithCell)
+	log.Println("warnings")
+	log.Println("  synapseMillivoltsOutOfRange", report.synapseMillivoltsOutOfRange)
+	log.Println("  selfLoopSynapses", report.selfLoopSynapses)
+	log.Println("  duplicateSynapses", report.duplicateSynapses)
+	log.Println("  orphanCells", report.orphanCells)
+}
+
+/*
+Errors counts the problems that make the network unsafe to use.
+*/
+func (report *IntegrityReport) Errors() int {
+	errors := len(report.cellIDMismatch) + len(report.synapseIDMismatch) +
+		len(report.synapseHasMissingDendriteCell) + len(report.synapseHasMissingAxonCell) +
+		len(report.synapseNotOnAxonCell) + len(report.synapseNotOnDendriteCell) +
+		len(report.synapseSignDisagreesWithCell)
+	for _, byCell := range []map[CellID][]SynapseID{
+		report.cellHasMissingAxonSynapse, report.cellHasMissingDendriteSynapse,
+		report.cellHasForeignAxonSynapse, report.cellHasForeignDendriteSynapse,
+	} {
+		for _, synapses := range byCell {
+			errors += len(synapses)
+		}
+	}
+	return errors
+}
+
+/*
+Warnings counts the problems that are allowed, but suspicious.
+*/
+func (report *IntegrityReport) Warnings() int {
+	return len(report.synapseMillivoltsOutOfRange) + len(report.selfLoopSynapses) +
+		len(report.duplicateSynapses) + len(report.orphanCells)
 }
 
 func (report *IntegrityReport) isOK() bool {
-	return len(report.cellHasMissingAxonSynapse) == 0 && len(report.cellHasMissingDendriteSynapse) == 0 && len(report.synapseHasMissingAxonCell) == 0 && len(report.synapseHasMissingDendriteCell) == 0
+	return report.Errors() == 0
 }
 
 /*
-CheckIntegrity tells you whether a network has bad connections between cells.
+MarshalJSON writes the report as JSON, with every violation, for other programs
+to read. Maps are keyed by the cell or synapse with the problem.
+*/
+func (report IntegrityReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		OK       bool `json:"ok"`
+		Errors   int  `json:"errors"`
+		Warnings int  `json:"warnings"`
+
+		CellIDMismatch                map[int]CellID         `json:"cellIDMismatch"`
+		SynapseIDMismatch             map[int]SynapseID      `json:"synapseIDMismatch"`
+		CellHasMissingAxonSynapse     map[CellID][]SynapseID `json:"cellHasMissingAxonSynapse"`
+		CellHasMissingDendriteSynapse map[CellID][]SynapseID `json:"cellHasMissingDendriteSynapse"`
+		CellHasForeignAxonSynapse     map[CellID][]SynapseID `json:"cellHasForeignAxonSynapse"`
+		CellHasForeignDendriteSynapse map[CellID][]SynapseID `json:"cellHasForeignDendriteSynapse"`
+		SynapseHasMissingDendriteCell map[SynapseID]CellID   `json:"synapseHasMissingDendriteCell"`
+		SynapseHasMissingAxonCell     map[SynapseID]CellID   `json:"synapseHasMissingAxonCell"`
+		SynapseNotOnAxonCell          map[SynapseID]CellID   `json:"synapseNotOnAxonCell"`
+		SynapseNotOnDendriteCell      map[SynapseID]CellID   `json:"synapseNotOnDendriteCell"`
+		SynapseSignDisagreesWithCell  map[SynapseID]CellID   `json:"synapseSignDisagreesWithCell"`
+		SynapseMillivoltsOutOfRange   map[SynapseID]int16    `json:"synapseMillivoltsOutOfRange"`
+
+		SelfLoopSynapses  []SynapseID             `json:"selfLoopSynapses"`
+		DuplicateSynapses map[SynapseID]SynapseID `json:"duplicateSynapses"`
+		OrphanCells       []CellID                `json:"orphanCells"`
+	}{
+		report.isOK(), report.Errors(), report.Warnings(),
+		report.cellIDMismatch, report.synapseIDMismatch,
+		report.cellHasMissingAxonSynapse, report.cellHasMissingDendriteSynapse,
+		report.cellHasForeignAxonSynapse, report.cellHasForeignDendriteSynapse,
+		report.synapseHasMissingDendriteCell, report.synapseHasMissingAxonCell,
+		report.synapseNotOnAxonCell, report.synapseNotOnDendriteCell,
+		report.synapseSignDisagreesWithCell, report.synapseMillivoltsOutOfRange,
+		report.selfLoopSynapses, report.duplicateSynapses, report.orphanCells,
+	})
+}
+
+/*
+CheckIntegrity tells you whether a network has bad connections between cells,
+or synapses that break Dale's law by having a different sign than the type of
+the cell they come from. The report has every problem it found, including
+warnings that do not make the network fail.
 */
 func CheckIntegrity(network *Network) (bool, IntegrityReport) {
 	report := newIntegrityReport()
+	minMillivolts := network.Laws.ActualSynapseMin()
+	maxMillivolts := network.Laws.ActualSynapseMax()
 
 	for cellID, cell := range network.Cells {
 		wasRemoved := cell == nil
 		if wasRemoved {
 			continue
 		}
-		for synapseID := range cell.AxonSynapses {
+		if cell.ID != CellID(cellID) {
+			report.cellIDMismatch[cellID] = cell.ID
+		}
+		for _, synapseID := range sortedSynapseIDs(cell.AxonSynapses) {
 			if ok := network.SynExists(synapseID); !ok {
-				report.cellHasMissingAxonSynapse[CellID(cellID)] = synapseID
+				report.cellHasMissingAxonSynapse[CellID(cellID)] = append(report.cellHasMissingAxonSynapse[CellID(cellID)], synapseID)
+			} else if network.GetSyn(synapseID).FromNeuronAxon != CellID(cellID) {
+				report.cellHasForeignAxonSynapse[CellID(cellID)] = append(report.cellHasForeignAxonSynapse[CellID(cellID)], synapseID)
 			}
 		}
-		for synapseID := range cell.DendriteSynapses {
+		for _, synapseID := range sortedSynapseIDs(cell.DendriteSynapses) {
 			if ok := network.SynExists(synapseID); !ok {
-				report.cellHasMissingDendriteSynapse[CellID(cellID)] = synapseID
+				report.cellHasMissingDendriteSynapse[CellID(cellID)] = append(report.cellHasMissingDendriteSynapse[CellID(cellID)], synapseID)
+			} else if network.GetSyn(synapseID).ToNeuronDendrite != CellID(cellID) {
+				report.cellHasForeignDendriteSynapse[CellID(cellID)] = append(report.cellHasForeignDendriteSynapse[CellID(cellID)], synapseID)
 			}
 		}
+		if len(cell.AxonSynapses) == 0 && len(cell.DendriteSynapses) == 0 && !cell.Immortal {
+			report.orphanCells = append(report.orphanCells, CellID(cellID))
+		}
 	}
 
+	type link struct{ from, to CellID }
+	firstLink := make(map[link]SynapseID)
 	for synapseID, synapse := range network.Synapses {
 		if synapse == nil {
 			continue
 		}
+		if synapse.ID != SynapseID(synapseID) {
+			report.synapseIDMismatch[synapseID] = synapse.ID
+		}
 		if ok := network.CellExists(synapse.FromNeuronAxon); !ok {
 			report.synapseHasMissingAxonCell[SynapseID(synapseID)] = synapse.FromNeuronAxon
+		} else {
+			from := network.GetCell(synapse.FromNeuronAxon)
+			if !from.Type.allows(synapse.Millivolts) {
+				report.synapseSignDisagreesWithCell[SynapseID(synapseID)] = synapse.FromNeuronAxon
+			}
+			if !from.AxonSynapses[SynapseID(synapseID)] {
+				report.synapseNotOnAxonCell[SynapseID(synapseID)] = synapse.FromNeuronAxon
+			}
 		}
 		if ok := network.CellExists(synapse.ToNeuronDendrite); !ok {
 			report.synapseHasMissingDendriteCell[SynapseID(synapseID)] = synapse.ToNeuronDendrite
+		} else if !network.GetCell(synapse.ToNeuronDendrite).DendriteSynapses[SynapseID(synapseID)] {
+			report.synapseNotOnDendriteCell[SynapseID(synapseID)] = synapse.ToNeuronDendrite
+		}
+		if synapse.Millivolts < minMillivolts || synapse.Millivolts > maxMillivolts {
+			report.synapseMillivoltsOutOfRange[SynapseID(synapseID)] = synapse.Millivolts
+		}
+
+		if synapse.FromNeuronAxon == synapse.ToNeuronDendrite {
+			report.selfLoopSynapses = append(report.selfLoopSynapses, SynapseID(synapseID))
+		}
+		l := link{synapse.FromNeuronAxon, synapse.ToNeuronDendrite}
+		if first, seen := firstLink[l]; seen {
+			report.duplicateSynapses[SynapseID(synapseID)] = first
+		} else {
+			firstLink[l] = SynapseID(synapseID)
 		}
 	}
 
@@ -74,3 +240,16 @@ func CheckIntegrity(network *Network) (bool, IntegrityReport) {
 
 	return ok, report
 }
+
+/*
+CheckFileIntegrity loads a saved network without refusing it for bad
+integrity, and checks it.
+*/
+func CheckFileIntegrity(filepath string) (bool, IntegrityReport, error) {
+	network, _, err := loadNetworkFromFile(filepath, false)
+	if err != nil {
+		return false, newIntegrityReport(), err
+	}
+	ok, report := CheckIntegrity(network)
+	return ok, report, nil
+}
@@ -1,6 +1,7 @@
 package potential
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -25,7 +26,7 @@ func Test_Integrity(t *testing.T) {
 		assert.Equal(t, 0, len(report.synapseHasMissingDendriteCell))
 		assert.Equal(t, 0, len(report.synapseHasMissingAxonCell))
 		assert.Equal(t, 0, len(report.cellHasMissingAxonSynapse))
-		assert.Equal(t, SynapseID(999), report.cellHasMissingDendriteSynapse[cell.ID])
+		assert.Equal(t, []SynapseID{999}, report.cellHasMissingDendriteSynapse[cell.ID])
 	})
 	t.Run("cell with bad axon synapse", func(t *testing.T) {
 		network := NewNetwork()
@@ -37,7 +38,7 @@ func Test_Integrity(t *testing.T) {
 		assert.Equal(t, 0, len(report.synapseHasMissingDendriteCell))
 		assert.Equal(t, 0, len(report.synapseHasMissingAxonCell))
 		assert.Equal(t, 0, len(report.cellHasMissingDendriteSynapse))
-		assert.Equal(t, SynapseID(7777), report.cellHasMissingAxonSynapse[cell.ID])
+		assert.Equal(t, []SynapseID{7777}, report.cellHasMissingAxonSynapse[cell.ID])
 	})
 	t.Run("synapse with bad dendrite cell", func(t *testing.T) {
 		network := NewNetwork()
@@ -67,4 +68,133 @@ func Test_Integrity(t *testing.T) {
 		assert.Equal(t, 0, len(report.cellHasMissingDendriteSynapse))
 		assert.Equal(t, CellID(2), report.synapseHasMissingAxonCell[synapse.ID])
 	})
+	t.Run("synapse with a different sign than its axon cell type", func(t *testing.T) {
+		network := NewNetwork()
+		excitatory := NewCell(network)
+		excitatory.Type = ExcitatoryCell
+		inhibitory := NewCell(network)
+		inhibitory.Type = InhibitoryCell
+		mixed := NewCell(network)
+		good := network.linkCells(excitatory.ID, inhibitory.ID)
+		good.Millivolts = 10
+		bad := network.linkCells(inhibitory.ID, excitatory.ID)
+		bad.Millivolts = 10
+		either := network.linkCells(mixed.ID, excitatory.ID)
+		either.Millivolts = -10
+		ok, report := CheckIntegrity(network)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, map[SynapseID]CellID{bad.ID: inhibitory.ID}, report.synapseSignDisagreesWithCell)
+	})
+	t.Run("collects every missing synapse on a cell", func(t *testing.T) {
+		network := NewNetwork()
+		cell := NewCell(network)
+		cell.AxonSynapses[30] = true
+		cell.AxonSynapses[10] = true
+		cell.AxonSynapses[20] = true
+		ok, report := CheckIntegrity(network)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, []SynapseID{10, 20, 30}, report.cellHasMissingAxonSynapse[cell.ID])
+		assert.Equal(t, 3, report.Errors())
+	})
+	t.Run("ID not matching its place in the network", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		synapse := network.linkCells(a.ID, b.ID)
+		b.ID = 5
+		synapse.ID = 9
+		_, report := CheckIntegrity(network)
+		assert.Equal(t, map[int]CellID{1: 5}, report.cellIDMismatch)
+		assert.Equal(t, map[int]SynapseID{0: 9}, report.synapseIDMismatch)
+	})
+	t.Run("synapse listed on a cell it does not connect to", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		c := NewCell(network)
+		synapse := network.linkCells(a.ID, b.ID)
+		c.AxonSynapses[synapse.ID] = true
+		c.DendriteSynapses[synapse.ID] = true
+		ok, report := CheckIntegrity(network)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, map[CellID][]SynapseID{c.ID: {synapse.ID}}, report.cellHasForeignAxonSynapse)
+		assert.Equal(t, map[CellID][]SynapseID{c.ID: {synapse.ID}}, report.cellHasForeignDendriteSynapse)
+	})
+	t.Run("synapse not listed on its cells", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		synapse := network.linkCells(a.ID, b.ID)
+		delete(a.AxonSynapses, synapse.ID)
+		delete(b.DendriteSynapses, synapse.ID)
+		ok, report := CheckIntegrity(network)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, map[SynapseID]CellID{synapse.ID: a.ID}, report.synapseNotOnAxonCell)
+		assert.Equal(t, map[SynapseID]CellID{synapse.ID: b.ID}, report.synapseNotOnDendriteCell)
+	})
+	t.Run("millivolts out of range are warnings", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		high := network.linkCells(a.ID, b.ID)
+		high.Millivolts = network.Laws.ActualSynapseMax() + 1
+		low := network.linkCells(b.ID, a.ID)
+		low.Millivolts = network.Laws.ActualSynapseMin() - 1
+		ok, report := CheckIntegrity(network)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 2, report.Warnings())
+		assert.Equal(t, map[SynapseID]int16{high.ID: high.Millivolts, low.ID: low.Millivolts},
+			report.synapseMillivoltsOutOfRange)
+	})
+	t.Run("network saved after raising SynapseLearnRate still loads", func(t *testing.T) {
+		filepath := "_network_learnrate.test.nur"
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		a.Type = ExcitatoryCell
+		ab := network.linkCells(a.ID, b.ID)
+		ab.Millivolts = network.Laws.ActualSynapseMax()
+		network.Laws.SynapseLearnRate += 100
+		assert.NoError(t, network.SaveToFile(filepath))
+		loaded, err := LoadNetworkFromFile(filepath)
+		assert.NoError(t, err)
+		_, report := CheckIntegrity(loaded)
+		assert.Equal(t, 1, len(report.synapseMillivoltsOutOfRange))
+	})
+	t.Run("self-loops, duplicates and orphans are warnings", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		orphan := NewCell(network)
+		immortal := NewCell(network)
+		immortal.Immortal = true
+		loop := network.linkCells(a.ID, a.ID)
+		first := network.linkCells(a.ID, b.ID)
+		second := network.linkCells(a.ID, b.ID)
+		ok, report := CheckIntegrity(network)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 0, report.Errors())
+		assert.Equal(t, 3, report.Warnings())
+		assert.Equal(t, []SynapseID{loop.ID}, report.selfLoopSynapses)
+		assert.Equal(t, map[SynapseID]SynapseID{second.ID: first.ID}, report.duplicateSynapses)
+		assert.Equal(t, []CellID{orphan.ID}, report.orphanCells)
+	})
+	t.Run("report is machine-readable JSON", func(t *testing.T) {
+		network := NewNetwork()
+		cell := NewCell(network)
+		cell.DendriteSynapses[999] = true
+		cell.DendriteSynapses[998] = true
+		_, report := CheckIntegrity(network)
+		data, err := json.Marshal(report)
+		assert.NoError(t, err)
+		var decoded struct {
+			OK                            bool
+			Errors                        int
+			CellHasMissingDendriteSynapse map[string][]SynapseID
+		}
+		assert.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, false, decoded.OK)
+		assert.Equal(t, 2, decoded.Errors)
+		assert.Equal(t, []SynapseID{998, 999}, decoded.CellHasMissingDendriteSynapse["0"])
+	})
 }
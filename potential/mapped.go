@@ -0,0 +1,297 @@
+package potential
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// mappedStride is how many slots apart the offsets in a mapped file's index are.
+const mappedStride = 64
+
+/*
+nurMapping is a binary .nur file mapped into memory, with an index to find the
+cells and synapses in it.
+
+Records are not all the same size, so the index has the offset of every
+`mappedStride`th slot, and the slots between are found by reading forward.
+A bit per slot remembers which have been read, including pruned ones.
+*/
+type nurMapping struct {
+	data  []byte
+	unmap func() error
+
+	cellOffsets    []int64
+	synapseOffsets []int64
+	cellsRead      []uint64
+	synapsesRead   []uint64
+
+	mux sync.Mutex
+}
+
+/*
+OpenMappedNetwork opens a network saved in the binary .nur format without
+reading it into memory. The file is memory-mapped, and each cell and synapse
+is read from it the first time GetCell or GetSyn asks for it. That lets
+inference run on networks larger than RAM, because firing only ever touches a
+part of the network.
+
+The network is read-only. It cannot be saved, and it does not learn - its
+plasticity rule is `none` and its synapses do not decay. Cells and synapses
+stay in memory once they are read, because firing changes their voltage.
+
+Cells that were never read are nil in `Network.Cells`, like pruned ones, so
+code that loops over every cell only sees the ones read so far. Use EachCell
+and EachSynapse to visit the whole network without keeping it in memory.
+Integrity is not checked, because that needs the whole network, but the file's
+checksums are.
+
+Call Close when done with the network. Networks in other formats can be
+converted with `nt export network.nur binary`.
+*/
+func OpenMappedNetwork(filepath string) (*Network, error) {
+	network := NewNetwork()
+	file, err := os.Open(filepath)
+	if err != nil {
+		return network, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return network, err
+	}
+	if info.Size() < int64(len(nurMagic)) {
+		return network, fmt.Errorf("Cannot map %s, it is not a binary network file", filepath)
+	}
+	data, unmap, err := mapFile(file, info.Size())
+	if err != nil {
+		return network, err
+	}
+	mapping := &nurMapping{data: data, unmap: unmap}
+	if string(data[:len(nurMagic)]) != nurMagic {
+		mapping.unmap()
+		return network, fmt.Errorf("Cannot map %s, it is not a binary network file - convert it with `nt export %s binary`",
+			filepath, filepath)
+	}
+	if err = network.indexMapping(mapping); err != nil {
+		mapping.unmap()
+		return network, fmt.Errorf("Cannot map network from file %s: %s", filepath, err)
+	}
+	if network.SchemaVersion != CurrentSchemaVersion {
+		mapping.unmap()
+		return network, fmt.Errorf("Cannot map network from file %s with schema version %d, run `nt migrate` on it first",
+			filepath, network.SchemaVersion)
+	}
+	network.mapping = mapping
+
+	network.Laws.PlasticityRule = "none"
+	network.Laws.SynapseDecayInterval = 0
+	if err = network.Laws.Validate(); err != nil {
+		network.Close()
+		return network, fmt.Errorf("Cannot load network with bad laws from file %s: %s", filepath, err)
+	}
+	network.SetSeed(network.Seed)
+	return network, nil
+}
+
+/*
+indexMapping reads the whole mapped file once, checking its checksums and
+noting where the records are. The cells and synapses are left in the file.
+
+Integrity is not checked, but every ID a record refers to must be a slot in the
+file, or reading it later would panic.
+*/
+func (network *Network) indexMapping(mapping *nurMapping) error {
+	reader := bytes.NewReader(mapping.data)
+	offset := func() int64 { return int64(len(mapping.data) - reader.Len()) }
+	d := newNurDecoder(reader)
+	cellSlots, synapseSlots, err := network.readBinaryHeader(d)
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < cellSlots && d.err == nil; i++ {
+		if i%mappedStride == 0 {
+			mapping.cellOffsets = append(mapping.cellOffsets, offset())
+		}
+		if cell := network.readBinaryCell(d, CellID(i)); cell != nil && d.err == nil {
+			for _, synapses := range []map[SynapseID]bool{cell.AxonSynapses, cell.DendriteSynapses} {
+				for synapseID := range synapses {
+					if uint64(synapseID) >= synapseSlots {
+						return fmt.Errorf("cell %d has synapse %d, but there are only %d synapses", i, synapseID, synapseSlots)
+					}
+				}
+			}
+		}
+	}
+	d.checksum("cells")
+	for i := uint64(0); i < synapseSlots && d.err == nil; i++ {
+		if i%mappedStride == 0 {
+			mapping.synapseOffsets = append(mapping.synapseOffsets, offset())
+		}
+		if synapse := network.readBinarySynapse(d, SynapseID(i)); synapse != nil && d.err == nil {
+			if uint64(synapse.FromNeuronAxon) >= cellSlots || uint64(synapse.ToNeuronDendrite) >= cellSlots {
+				return fmt.Errorf("synapse %d connects cells %d and %d, but there are only %d cells",
+					i, synapse.FromNeuronAxon, synapse.ToNeuronDendrite, cellSlots)
+			}
+		}
+	}
+	d.checksum("synapses")
+	if d.err != nil {
+		return d.err
+	}
+
+	network.Cells = make([]*Cell, cellSlots)
+	network.Synapses = make([]*Synapse, synapseSlots)
+	mapping.cellsRead = make([]uint64, (cellSlots+63)/64)
+	mapping.synapsesRead = make([]uint64, (synapseSlots+63)/64)
+	return nil
+}
+
+// decoderAt starts reading the mapped file at the indexed slot before i.
+func (mapping *nurMapping) decoderAt(offsets []int64, i int) *nurDecoder {
+	return newNurDecoder(bytes.NewReader(mapping.data[offsets[i/mappedStride]:]))
+}
+
+/*
+mappedCell reads a cell from the mapped file. Pruned cells are nil.
+*/
+func (network *Network) mappedCell(cellID CellID) *Cell {
+	mapping := network.mapping
+	mapping.mux.Lock()
+	defer mapping.mux.Unlock()
+	d := mapping.decoderAt(mapping.cellOffsets, int(cellID))
+	first := CellID(int(cellID) / mappedStride * mappedStride)
+	for id := first; id < cellID; id++ {
+		network.readBinaryCell(d, id)
+	}
+	return network.readBinaryCell(d, cellID)
+}
+
+/*
+mappedSynapse reads a synapse from the mapped file. Pruned synapses are nil.
+*/
+func (network *Network) mappedSynapse(synapseID SynapseID) *Synapse {
+	mapping := network.mapping
+	mapping.mux.Lock()
+	defer mapping.mux.Unlock()
+	d := mapping.decoderAt(mapping.synapseOffsets, int(synapseID))
+	first := SynapseID(int(synapseID) / mappedStride * mappedStride)
+	for id := first; id < synapseID; id++ {
+		network.readBinarySynapse(d, id)
+	}
+	return network.readBinarySynapse(d, synapseID)
+}
+
+// isRead is whether slot i was read from the file, or is past the end of it.
+func isRead(bits []uint64, i int) bool {
+	return i >= len(bits)*64 || bits[i/64]&(1<<uint(i%64)) != 0
+}
+
+func markRead(bits []uint64, i int) {
+	bits[i/64] |= 1 << uint(i%64)
+}
+
+/*
+resolveCell reads a cell from the mapped file the first time it is asked for,
+and keeps it. The caller holds cellMux.
+*/
+func (network *Network) resolveCell(cellID CellID) *Cell {
+	if isRead(network.mapping.cellsRead, int(cellID)) {
+		return network.Cells[cellID]
+	}
+	cell := network.mappedCell(cellID)
+	network.Cells[cellID] = cell
+	markRead(network.mapping.cellsRead, int(cellID))
+	if cell != nil {
+		network.markActive(cell)
+	}
+	return cell
+}
+
+/*
+resolveSynapse reads a synapse from the mapped file the first time it is asked
+for, and keeps it. The cell it fires is read too, because Step reaches it
+without GetCell. The caller holds synMux.
+*/
+func (network *Network) resolveSynapse(synapseID SynapseID) *Synapse {
+	if isRead(network.mapping.synapsesRead, int(synapseID)) {
+		return network.Synapses[synapseID]
+	}
+	synapse := network.mappedSynapse(synapseID)
+	network.Synapses[synapseID] = synapse
+	markRead(network.mapping.synapsesRead, int(synapseID))
+	if synapse != nil {
+		network.GetCell(synapse.ToNeuronDendrite)
+	}
+	return synapse
+}
+
+/*
+Mapped is whether the network was opened with OpenMappedNetwork.
+*/
+func (network *Network) Mapped() bool {
+	return network.mapping != nil
+}
+
+/*
+Close releases the file of a network opened with OpenMappedNetwork. The
+network cannot be used after. Other networks have nothing to close.
+*/
+func (network *Network) Close() error {
+	if network.mapping == nil {
+		return nil
+	}
+	err := network.mapping.unmap()
+	network.mapping = nil
+	return err
+}
+
+// errMappedReadOnly is returned when saving a mapped network.
+var errMappedReadOnly = errors.New("Cannot save a memory-mapped network, it is read-only")
+
+/*
+peekCell is the cell in a slot, read from the file without keeping it when the
+network is mapped and it was not read yet.
+*/
+func (network *Network) peekCell(id int) *Cell {
+	if network.mapping != nil && !isRead(network.mapping.cellsRead, id) {
+		return network.mappedCell(CellID(id))
+	}
+	return network.Cells[id]
+}
+
+// peekSynapse is peekCell for synapses.
+func (network *Network) peekSynapse(id int) *Synapse {
+	if network.mapping != nil && !isRead(network.mapping.synapsesRead, id) {
+		return network.mappedSynapse(SynapseID(id))
+	}
+	return network.Synapses[id]
+}
+
+/*
+EachCell calls fn with every cell in the network, in order, skipping pruned
+cells. Mapped networks read the cells that are still in the file without
+keeping them, so the whole network never has to fit in memory.
+*/
+func (network *Network) EachCell(fn func(*Cell)) {
+	for id := range network.Cells {
+		if cell := network.peekCell(id); cell != nil {
+			fn(cell)
+		}
+	}
+}
+
+/*
+EachSynapse calls fn with every synapse in the network, in order, skipping
+pruned synapses. Like EachCell, mapped networks do not keep what they read.
+*/
+func (network *Network) EachSynapse(fn func(*Synapse)) {
+	for id := range network.Synapses {
+		if synapse := network.peekSynapse(id); synapse != nil {
+			fn(synapse)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OpenMappedNetwork(t *testing.T) {
+	var network *Network
+	before := func() {
+		network = NewNetwork()
+		network.SetSeed(7)
+		network.Laws.NoiseRatio = 0
+		network.Grow(200, 6, 50)
+		network.GetCell(130).Tag = "tagged"
+		network.PruneSynapse(65)
+		assert.NoError(t, network.SaveToFileBinary("_network_mapped.test.nur"))
+	}
+
+	t.Run("reads cells and synapses only when asked for", func(t *testing.T) {
+		before()
+		mapped, err := OpenMappedNetwork("_network_mapped.test.nur")
+		assert.NoError(t, err)
+		defer mapped.Close()
+		assert.True(t, mapped.Mapped())
+		assert.Equal(t, len(network.Cells), len(mapped.Cells))
+		assert.Nil(t, mapped.Cells[130])
+
+		cell := mapped.GetCell(130)
+		assert.Equal(t, "tagged", cell.Tag)
+		assert.Equal(t, network.GetCell(130).AxonSynapses, cell.AxonSynapses)
+		assert.Equal(t, cell, mapped.Cells[130])
+		assert.False(t, mapped.SynExists(65))
+		assert.Equal(t, network.GetSyn(66).Millivolts, mapped.GetSyn(66).Millivolts)
+	})
+	t.Run("fires the same as the loaded network without learning", func(t *testing.T) {
+		before()
+		loaded, err := LoadNetworkFromFile("_network_mapped.test.nur")
+		assert.NoError(t, err)
+		loaded.Laws.PlasticityRule = "none"
+		loaded.Laws.SynapseDecayInterval = 0
+		mapped, err := OpenMappedNetwork("_network_mapped.test.nur")
+		assert.NoError(t, err)
+		defer mapped.Close()
+
+		seed := FiringPattern{1: 1, 2: 1, 3: 1}
+		assert.Equal(t, FireNetworkUntilDone(loaded, seed), FireNetworkUntilDone(mapped, seed))
+		assert.Equal(t, "none", mapped.Laws.PlasticityRule)
+	})
+	t.Run("visits every cell without keeping them", func(t *testing.T) {
+		before()
+		mapped, err := OpenMappedNetwork("_network_mapped.test.nur")
+		assert.NoError(t, err)
+		defer mapped.Close()
+		cells, synapses := 0, 0
+		mapped.EachCell(func(*Cell) { cells++ })
+		mapped.EachSynapse(func(*Synapse) { synapses++ })
+		assert.Equal(t, 200, cells)
+		assert.Equal(t, len(network.Synapses)-1, synapses)
+		assert.Nil(t, mapped.Cells[0])
+	})
+	t.Run("is read-only", func(t *testing.T) {
+		before()
+		mapped, err := OpenMappedNetwork("_network_mapped.test.nur")
+		assert.NoError(t, err)
+		defer mapped.Close()
+		assert.Error(t, mapped.SaveToFile("_network_mapped_save.test.nur"))
+		assert.Error(t, mapped.SaveToFileBinary("_network_mapped_save.test.nur"))
+	})
+	t.Run("needs the binary format", func(t *testing.T) {
+		before()
+		assert.NoError(t, network.SaveToFile("_network_mapped_gz.test.nur"))
+		_, err := OpenMappedNetwork("_network_mapped_gz.test.nur")
+		assert.Error(t, err)
+	})
+	t.Run("refuses a file that refers past its slots", func(t *testing.T) {
+		before()
+		network.GetSyn(2).ToNeuronDendrite = 999
+		assert.NoError(t, network.SaveToFileBinary("_network_mapped_bad.test.nur"))
+		_, err := OpenMappedNetwork("_network_mapped_bad.test.nur")
+		assert.Error(t, err)
+
+		before()
+		network.GetCell(3).AxonSynapses[SynapseID(len(network.Synapses))] = true
+		assert.NoError(t, network.SaveToFileBinary("_network_mapped_bad.test.nur"))
+		_, err = OpenMappedNetwork("_network_mapped_bad.test.nur")
+		assert.Error(t, err)
+	})
+}
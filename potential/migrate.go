@@ -0,0 +1,224 @@
+package potential
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ruffrey/nurtrace/laws"
+)
+
+/*
+CurrentSchemaVersion is the version of the network and vocab fields this
+package saves. Files saved before versioning have none, which is version 0.
+
+Bump it whenever a change to Network, Cell, Synapse or Vocabulary would stop
+older files loading the same, and register a migration from the old version.
+*/
+const CurrentSchemaVersion = 1
+
+/*
+Migration upgrades a saved network or vocab from one schema version to the
+next. It works on the decoded JSON, because an older file may not fit the
+structs any more.
+*/
+type Migration struct {
+	// From is the version this migration upgrades. It produces From+1.
+	From        int
+	Description string
+	/*
+		Migrate changes the document in place, and returns a line about each
+		thing it changed, for the report.
+	*/
+	Migrate func(doc map[string]interface{}) (changes []string, err error)
+}
+
+var networkMigrations = map[int]Migration{
+	0: {
+		From:        0,
+		Description: "stamp the schema version, and give networks saved before laws were configurable the default laws",
+		Migrate: func(doc map[string]interface{}) (changes []string, err error) {
+			if doc["Laws"] != nil {
+				return nil, nil
+			}
+			defaults, err := toDocumentValue(laws.DefaultLaws())
+			if err != nil {
+				return nil, err
+			}
+			doc["Laws"] = defaults
+			return []string{"added the default laws"}, nil
+		},
+	},
+}
+
+var vocabMigrations = map[int]Migration{
+	0: {
+		From:        0,
+		Description: "stamp the schema version",
+		Migrate: func(doc map[string]interface{}) (changes []string, err error) {
+			return nil, nil
+		},
+	},
+}
+
+/*
+RegisterNetworkMigration adds a migration for saved networks, replacing any
+other from the same version. Like RegisterPlasticityRule, it should be called
+from `init()`.
+*/
+func RegisterNetworkMigration(migration Migration) {
+	networkMigrations[migration.From] = migration
+}
+
+/*
+RegisterVocabMigration adds a migration for saved vocabs, replacing any other
+from the same version.
+*/
+func RegisterVocabMigration(migration Migration) {
+	vocabMigrations[migration.From] = migration
+}
+
+// toDocumentValue turns a struct into the generic JSON value migrations use.
+func toDocumentValue(v interface{}) (value interface{}, err error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(buf, &value)
+	return value, err
+}
+
+/*
+MigrationReport describes how a saved network or vocab was upgraded.
+*/
+type MigrationReport struct {
+	// Kind is "network" or "vocab".
+	Kind string
+	// Format is how the file was saved: "binary", "gzip-json" or "json".
+	Format string
+	From   int
+	To     int
+	// Changes are what each migration did, in order.
+	Changes []string
+}
+
+/*
+Migrated is whether the file was older than the current schema.
+*/
+func (report *MigrationReport) Migrated() bool {
+	return report.From != report.To
+}
+
+/*
+Print logs the report.
+*/
+func (report *MigrationReport) Print() {
+	if !report.Migrated() {
+		log.Println(strings.Title(report.Kind), "is already at schema version", report.To)
+		return
+	}
+	log.Println(strings.Title(report.Kind), "migrated from schema version", report.From, "to", report.To)
+	for _, change := range report.Changes {
+		log.Println("  -", change)
+	}
+}
+
+/*
+schemaVersionOf reads just the version of a saved network or vocab, so files
+that are already current are decoded only once.
+*/
+func schemaVersionOf(data []byte) (int, error) {
+	var versioned struct{ SchemaVersion int }
+	err := json.Unmarshal(data, &versioned)
+	return versioned.SchemaVersion, err
+}
+
+/*
+migrateJSON runs the migrations from the document's version up to the current
+one, in order, and returns the upgraded JSON. Current documents come back as
+they are.
+*/
+func migrateJSON(kind string, data []byte, migrations map[int]Migration) ([]byte, *MigrationReport, error) {
+	from, err := schemaVersionOf(data)
+	if err != nil {
+		return data, nil, err
+	}
+	report := &MigrationReport{Kind: kind, From: from, To: CurrentSchemaVersion}
+	if from > CurrentSchemaVersion {
+		return data, report, fmt.Errorf("The %s has schema version %d, which is newer than this version of nurtrace understands (%d)",
+			kind, from, CurrentSchemaVersion)
+	}
+	if from == CurrentSchemaVersion {
+		return data, report, nil
+	}
+
+	var doc map[string]interface{}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return data, report, err
+	}
+	for version := from; version < CurrentSchemaVersion; version++ {
+		migration, ok := migrations[version]
+		if !ok {
+			return data, report, fmt.Errorf("No %s migration from schema version %d", kind, version)
+		}
+		changes, err := migration.Migrate(doc)
+		if err != nil {
+			return data, report, fmt.Errorf("Migrating %s from schema version %d: %s", kind, version, err)
+		}
+		report.Changes = append(report.Changes, fmt.Sprintf("%d -> %d: %s", version, version+1, migration.Description))
+		for _, change := range changes {
+			report.Changes = append(report.Changes, "  "+change)
+		}
+		doc["SchemaVersion"] = version + 1
+	}
+	data, err = json.Marshal(doc)
+	return data, report, err
+}
+
+/*
+MigrateNetworkJSON upgrades a network saved as JSON to the current schema
+version.
+*/
+func MigrateNetworkJSON(data []byte) ([]byte, *MigrationReport, error) {
+	return migrateJSON("network", data, networkMigrations)
+}
+
+/*
+MigrateVocabJSON upgrades a vocab saved as JSON to the current schema version.
+*/
+func MigrateVocabJSON(data []byte) ([]byte, *MigrationReport, error) {
+	return migrateJSON("vocab", data, vocabMigrations)
+}
+
+/*
+MigrateNetworkFile upgrades a saved network to the current schema version and
+rewrites it in the same format. Files that are already current are left alone.
+*/
+func MigrateNetworkFile(filepath string) (*MigrationReport, error) {
+	network, report, err := loadNetworkFromFile(filepath, true)
+	if err != nil || !report.Migrated() {
+		return report, err
+	}
+	switch report.Format {
+	case "binary":
+		err = network.SaveToFileBinary(filepath)
+	case "json":
+		err = network.SaveToFileReadable(filepath)
+	default:
+		err = network.SaveToFile(filepath)
+	}
+	return report, err
+}
+
+/*
+MigrateVocabFile upgrades a saved vocab to the current schema version and
+rewrites it. Files that are already current are left alone.
+*/
+func MigrateVocabFile(filepath string) (*MigrationReport, error) {
+	vocab, report, err := loadVocabFromFile(filepath)
+	if err != nil || !report.Migrated() {
+		return report, err
+	}
+	return report, vocab.SaveToFile(filepath)
+}
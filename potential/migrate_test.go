@@ -0,0 +1,84 @@
+package potential
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ruffrey/nurtrace/laws"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Migrate(t *testing.T) {
+	t.Run("new networks and vocabs are saved at the current version", func(t *testing.T) {
+		network := NewNetwork()
+		NewCell(network)
+		vocab := NewVocabulary(network)
+		assert.NoError(t, network.SaveToFile("_network_version.test.nur"))
+		assert.NoError(t, vocab.SaveToFile("_vocab_version.test.json"))
+		net2, err := LoadNetworkFromFile("_network_version.test.nur")
+		assert.NoError(t, err)
+		assert.Equal(t, CurrentSchemaVersion, net2.SchemaVersion)
+		vocab2, err := LoadVocabFromFile("_vocab_version.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, CurrentSchemaVersion, vocab2.SchemaVersion)
+	})
+	t.Run("upgrades an unversioned network step by step", func(t *testing.T) {
+		data, report, err := MigrateNetworkJSON([]byte(`{"Cells":[],"Synapses":[]}`))
+		assert.NoError(t, err)
+		assert.True(t, report.Migrated())
+		assert.Equal(t, 0, report.From)
+		assert.Equal(t, CurrentSchemaVersion, report.To)
+		assert.Contains(t, report.Changes, "  added the default laws")
+		version, err := schemaVersionOf(data)
+		assert.NoError(t, err)
+		assert.Equal(t, CurrentSchemaVersion, version)
+	})
+	t.Run("leaves current files as they are", func(t *testing.T) {
+		network := NewNetwork()
+		data, err := network.ToJSON()
+		assert.NoError(t, err)
+		migrated, report, err := MigrateNetworkJSON(data)
+		assert.NoError(t, err)
+		assert.False(t, report.Migrated())
+		assert.Equal(t, data, migrated)
+	})
+	t.Run("refuses files from a newer version", func(t *testing.T) {
+		_, _, err := MigrateVocabJSON([]byte(`{"SchemaVersion":999}`))
+		assert.Error(t, err)
+	})
+	t.Run("rewrites files in the same format", func(t *testing.T) {
+		filepath := "_network_migrate.test.json"
+		err := ioutil.WriteFile(filepath, []byte(`{"Cells":[],"Synapses":[]}`), os.ModePerm)
+		assert.NoError(t, err)
+		report, err := MigrateNetworkFile(filepath)
+		assert.NoError(t, err)
+		assert.Equal(t, "json", report.Format)
+		assert.True(t, report.Migrated())
+
+		network, report, err := loadNetworkFromFile(filepath, true)
+		assert.NoError(t, err)
+		assert.False(t, report.Migrated())
+		assert.Equal(t, laws.DefaultLaws(), network.Laws)
+	})
+	t.Run("runs registered migrations", func(t *testing.T) {
+		original := vocabMigrations[0]
+		defer RegisterVocabMigration(original)
+		RegisterVocabMigration(Migration{
+			From:        0,
+			Description: "rename Threadz",
+			Migrate: func(doc map[string]interface{}) ([]string, error) {
+				doc["Threads"] = doc["Threadz"]
+				delete(doc, "Threadz")
+				return []string{"renamed Threadz to Threads"}, nil
+			},
+		})
+		filepath := "_vocab_migrate.test.json"
+		err := ioutil.WriteFile(filepath, []byte(`{"Threadz":3}`), os.ModePerm)
+		assert.NoError(t, err)
+		vocab, err := LoadVocabFromFile(filepath)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, vocab.Threads)
+		assert.Equal(t, CurrentSchemaVersion, vocab.SchemaVersion)
+	})
+}
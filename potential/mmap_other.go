@@ -0,0 +1,18 @@
+//go:build !unix
+
+package potential
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// mapFile reads the whole file where memory-mapping is not supported, so
+// mapped networks still work, just without saving memory.
+func mapFile(file *os.File, size int64) (data []byte, unmap func() error, err error) {
+	data, err = ioutil.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package potential
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps a whole file into memory, read-only.
+func mapFile(file *os.File, size int64) (data []byte, unmap func() error, err error) {
+	data, err = syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -1,15 +1,19 @@
 package potential
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
 	"math/rand"
 	"os"
+	"sort"
 	"sync"
+	"time"
 
 	"fmt"
 
@@ -25,6 +29,15 @@ type Network struct {
 		in a few milliseconds.
 	*/
 	Disabled bool
+	/*
+		StepWorkers is how many goroutines Step splits its work across. Zero or
+		one steps on a single goroutine. Only tallying the voltage and deciding
+		which cells reach their threshold run in parallel. Firing those cells and
+		the plasticity rule stay serial, in ID order, so the network fires the
+		same with any number of workers. It is not saved, because it depends on
+		the machine.
+	*/
+	StepWorkers int `json:"-"`
 	/*
 	   Synapses are where the magic happens.
 	*/
@@ -38,33 +51,103 @@ type Network struct {
 	Cells        []*Cell
 	CellIDCursor int
 	cellMux      sync.Mutex
+	/*
+		Laws are the ratios and constants this network lives by. They are saved
+		with the network.
+	*/
+	Laws *laws.Laws
+	/*
+		Seed is what the network's random number generator was last seeded with.
+		Growing, noise and new synapse weights all use the network's generator,
+		so two networks with the same seed and the same history are identical.
+		Only the seed is saved, not how far the generator got, so every load
+		starts the same random numbers over again.
+	*/
+	Seed int64
+	rng  *rand.Rand
+	/*
+		SchemaVersion is the version of the fields the network was saved with.
+		Loading upgrades older networks to CurrentSchemaVersion.
+	*/
+	SchemaVersion int
+	/*
+		pendingDeliveries is a ring buffer of synapses with a Delay, waiting to
+		apply their voltage. The slot at deliveryCursor is delivered on the next
+		Step.
+	*/
+	pendingDeliveries [][]SynapseID
+	deliveryCursor    int
+	totalPending      int
+	stepsSinceDecay   int
+	// steps is the clock for the plasticity rule
+	steps      int
+	plasticity PlasticityRule
+	/*
+		nextSynapses is the queue of synapses that fire on the next Step, and
+		activeCells are the cells that are not resting. Step only visits these.
+	*/
+	nextSynapses []SynapseID
+	activeCells  map[CellID]bool
+	lastFired    []CellID
+	// observers are told what happens as the network steps and learns
+	observers []StepObserver
+	/*
+		Regions are the named groups of cells in the network, and how they
+		connect. See Region.
+	*/
+	Regions map[string]*Region
+	// regionCells are the cells in each region, built when first needed
+	regionCells map[string][]CellID
+	// mapping is the file of a network opened with OpenMappedNetwork
+	mapping *nurMapping
 }
 
 /*
-NewNetwork is a constructor that, which also happens to reset the random number generator
-when called. Seems like a good time.
+NewNetwork is a constructor that, which also happens to seed the network's random number
+generator from the current time. Use SetSeed for a repeatable network.
 */
 func NewNetwork() *Network {
 	n := Network{
 		Disabled: false,
 		Synapses: make([]*Synapse, 0),
 		Cells:    make([]*Cell, 0),
+		Laws:     laws.DefaultLaws(),
+
+		SchemaVersion: CurrentSchemaVersion,
+
+		activeCells: make(map[CellID]bool),
 	}
+	n.SetSeed(time.Now().UnixNano())
 	return &n
 }
 
+/*
+SetSeed resets the network's random number generator, and records the seed so
+it is saved with the network.
+*/
+func (network *Network) SetSeed(seed int64) {
+	network.Seed = seed
+	network.rng = rand.New(rand.NewSource(seed))
+}
+
 /*
 linkCells creates a new synapse and links the two referenced cells where the
-"to" cell has an axon firing the "from" cell's dendrite.
+"to" cell has an axon firing the "from" cell's dendrite. The synapse gets the
+sign of the "from" cell's type.
 */
 func (network *Network) linkCells(fromCellID CellID, toCellID CellID) *Synapse {
 	fromCell := network.GetCell(fromCellID)
 	toCell := network.GetCell(toCellID)
 
 	synapse := NewSynapse(network)
+	synapse.Millivolts = fromCell.Type.signed(synapse.Millivolts)
 	fromCell.addAxon(synapse.ID)
 	toCell.addDendrite(synapse.ID)
 
+	for _, observer := range network.observers {
+		observer.SynapseCreated(network, synapse.ID)
+	}
+
 	return synapse
 }
 
@@ -74,6 +157,9 @@ GetCell safely returns a cell object so you don't have to use mutexes.
 func (network *Network) GetCell(cellID CellID) *Cell {
 	network.cellMux.Lock()
 	cell := network.Cells[cellID]
+	if cell == nil && network.mapping != nil {
+		cell = network.resolveCell(cellID)
+	}
 	network.cellMux.Unlock()
 	return cell
 }
@@ -84,6 +170,9 @@ GetSyn safely returns a synapse object so you don't have to use mutexes.
 func (network *Network) GetSyn(synapseID SynapseID) *Synapse {
 	network.synMux.Lock()
 	synapse := network.Synapses[synapseID]
+	if synapse == nil && network.mapping != nil {
+		synapse = network.resolveSynapse(synapseID)
+	}
 	network.synMux.Unlock()
 	return synapse
 }
@@ -110,52 +199,59 @@ func (network *Network) SynExists(synapseID SynapseID) bool {
 	return true
 }
 
-func randomIntBetween(min, max int) int {
-	return rand.Intn((max+1)-min) + min
+func (network *Network) randomIntBetween(min, max int) int {
+	return network.rng.Intn((max+1)-min) + min
 }
 
 // randCell returns a random CellID from a map where cells are the keys.
 // probably could combine with RandCellKey
-func randCellFromMap(cellMap map[CellID]bool) (randCellID CellID) {
-	iterate := randomIntBetween(0, len(cellMap)-1)
-	i := 0
+func (network *Network) randCellFromMap(cellMap map[CellID]bool) (randCellID CellID) {
+	return network.randCellFrom(sortedCellIDs(cellMap))
+}
+
+// randCellFrom picks from cells that are already sorted, for callers picking
+// from the same cells many times.
+func (network *Network) randCellFrom(sortedCells []CellID) (randCellID CellID) {
+	return sortedCells[network.randomIntBetween(0, len(sortedCells)-1)]
+}
+
+func (network *Network) randSynapseFromMap(synapseMap map[SynapseID]bool) (randSynapseID SynapseID) {
+	keys := sortedSynapseIDs(synapseMap)
+	return keys[network.randomIntBetween(0, len(keys)-1)]
+}
+
+// sortedCellIDs returns the keys of the map in order, so walking a map does
+// not depend on Go's random map ordering.
+func sortedCellIDs(cellMap map[CellID]bool) []CellID {
+	keys := make([]CellID, 0, len(cellMap))
 	for k := range cellMap {
-		if i == iterate {
-			randCellID = CellID(k)
-			break
-		}
-		i++
+		keys = append(keys, k)
 	}
-	return randCellID
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
 }
 
-func randSynapseFromMap(synapseMap map[SynapseID]bool) (randSynapseID SynapseID) {
-	iterate := randomIntBetween(0, len(synapseMap)-1)
-	i := 0
+// sortedSynapseIDs is the same as sortedCellIDs, for synapses.
+func sortedSynapseIDs(synapseMap map[SynapseID]bool) []SynapseID {
+	keys := make([]SynapseID, 0, len(synapseMap))
 	for k := range synapseMap {
-		if i == iterate {
-			randSynapseID = SynapseID(k)
-			break
-		}
-		i++
+		keys = append(keys, k)
 	}
-	return randSynapseID
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
 }
 
 /*
-Methods for random map keys below select a random integer between 0 and the map length,
-then interate into the map that many times to find the map key we want.
-Golang technically will not guarantee looping order over a map; but
-it is not truly random, so we have to do this expensive task of looping.
-It could likely be improved later by using a larger memory footprint
-that tracks all the keys in an array of integers.
+Methods for random map keys above sort the map keys, then select a random index
+from the network's generator. Golang does not guarantee looping order over a map,
+and it is not truly random either, so sorting is what makes them repeatable.
 */
 
 /*
 RandomCellKey gets the key of a random one in the array.
 */
 func (network *Network) RandomCellKey() (randCellID CellID) {
-	i := randomIntBetween(0, len(network.Cells)-1)
+	i := network.randomIntBetween(0, len(network.Cells)-1)
 	randCellID = CellID(i)
 	return randCellID
 }
@@ -171,6 +267,9 @@ func (network *Network) ResetForTraining() {
 		}
 		cell.postRefractoryReset()
 		cell.WasFired = false
+		if cell.isAdapted() {
+			cell.Threshold = 0
+		}
 	}
 	for _, synapse := range network.Synapses {
 		if synapse == nil { // pruned
@@ -178,6 +277,11 @@ func (network *Network) ResetForTraining() {
 		}
 		synapse.fireNextRound = false
 	}
+	network.clearPendingDeliveries()
+	network.nextSynapses = nil
+	network.activeCells = make(map[CellID]bool)
+	network.lastFired = nil
+	network.plasticityRule().Reset()
 
 	network.Disabled = false
 }
@@ -188,7 +292,8 @@ Print logs the network cells to console
 func (network *Network) Print() {
 	log.Println("----------")
 	log.Println("Network")
-	for id, cell := range network.Cells {
+	for id := range network.Cells {
+		cell := network.peekCell(id)
 		if cell == nil {
 			log.Println("  --------\nremoved cell=", id)
 			continue
@@ -198,7 +303,8 @@ func (network *Network) Print() {
 		log.Println("  synapses to axon=", cell.AxonSynapses)
 		log.Println("  synapses to dendrite=", cell.DendriteSynapses)
 	}
-	for id, syn := range network.Synapses {
+	for id := range network.Synapses {
+		syn := network.peekSynapse(id)
 		if syn == nil {
 			log.Println("  --------\nremoved synapse=", id)
 			continue
@@ -227,6 +333,9 @@ func (network *Network) PrintTotals() {
 ToJSON gives a json representation of the neural network.
 */
 func (network *Network) ToJSON() (buf []byte, err error) {
+	if network.mapping != nil {
+		return []byte{}, errMappedReadOnly
+	}
 	buf, err = json.Marshal(network)
 	if err != nil {
 		return []byte{}, err
@@ -266,6 +375,9 @@ func (network *Network) SaveToFile(filepath string) (err error) {
 SaveToFileReadable outputs the network to a file as gzipped JSON
 */
 func (network *Network) SaveToFileReadable(filepath string) (err error) {
+	if network.mapping != nil {
+		return errMappedReadOnly
+	}
 	buf, err := json.MarshalIndent(network, "", "  ")
 	if err != nil {
 		return err
@@ -278,52 +390,223 @@ func (network *Network) SaveToFileReadable(filepath string) (err error) {
 FireNoise chooses `NoiseRatio` random cells and fires them.
 */
 func (network *Network) FireNoise() {
-	totalFires := int(math.Ceil(float64(len(network.Cells)) * laws.NoiseRatio))
+	totalFires := int(math.Ceil(float64(len(network.Cells)) * network.Laws.NoiseRatio))
 	for i := 0; i < totalFires; i++ {
-		network.GetCell(network.RandomCellKey()).FireActionPotential()
+		cell := network.GetCell(network.RandomCellKey())
+		if cell == nil { // pruned
+			continue
+		}
+		cell.FireActionPotential()
 	}
 }
 
 /*
 LoadNetworkFromFile reads a saved network from disk and creates a new network from it.
+It reads the binary .nur format from SaveToFileBinary, and gzipped or plain
+JSON from SaveToFile and SaveToFileReadable.
 */
 func LoadNetworkFromFile(filepath string) (*Network, error) {
+	network, report, err := loadNetworkFromFile(filepath, true)
+	if err == nil && report.Migrated() {
+		log.Println("Upgraded network", filepath, "from schema version", report.From, "to", report.To,
+			"- use `nt migrate` to save it that way")
+	}
+	return network, err
+}
+
+/*
+loadNetworkFromFile is LoadNetworkFromFile, also reporting the file's format
+and how it was migrated. Without checkIntegrity, networks with bad integrity
+load anyway, so they can be repaired.
+
+Networks at the current schema version are streamed from the file a cell and a
+synapse at a time, so the file is never in memory all at once. Older networks
+are read whole, because migrations work on the whole document.
+*/
+func loadNetworkFromFile(filepath string, checkIntegrity bool) (*Network, *MigrationReport, error) {
 	network := NewNetwork()
-	file, err := os.Open(filepath)
+	report := &MigrationReport{Kind: "network", From: CurrentSchemaVersion, To: CurrentSchemaVersion}
+	reader, closeFile, err := openNetworkFile(filepath, report)
+	if err != nil {
+		return network, report, err
+	}
+
+	var jsonBytes []byte
+	if report.Format == "binary" {
+		err = network.readBinary(reader)
+		closeFile()
+		if err != nil {
+			return network, report, fmt.Errorf("Cannot load network from file %s: %s", filepath, err)
+		}
+		if network.SchemaVersion == CurrentSchemaVersion {
+			return network, report, network.afterLoad("file "+filepath, checkIntegrity)
+		}
+		// older schemas are migrated as JSON, like any other file
+		if jsonBytes, err = network.ToJSON(); err != nil {
+			return network, report, err
+		}
+	} else {
+		err = network.decodeJSONStream(reader)
+		closeFile()
+		if err == nil && network.SchemaVersion == CurrentSchemaVersion {
+			return network, report, network.afterLoad("file "+filepath, checkIntegrity)
+		}
+		// an older network, which may not even decode, so start again
+		if reader, closeFile, err = openNetworkFile(filepath, report); err != nil {
+			return network, report, err
+		}
+		jsonBytes, err = ioutil.ReadAll(reader)
+		closeFile()
+		if err != nil {
+			return network, report, err
+		}
+	}
+
+	network = NewNetwork()
+	jsonBytes, migrated, err := MigrateNetworkJSON(jsonBytes)
+	if migrated != nil {
+		migrated.Format = report.Format
+		report = migrated
+	}
 	if err != nil {
-		return network, err
+		return network, report, fmt.Errorf("Cannot load network from file %s: %s", filepath, err)
 	}
-	defer file.Close()
+	err = json.Unmarshal(jsonBytes, network)
+	if err != nil {
+		return network, report, err
+	}
+	return network, report, network.afterLoad("file "+filepath, checkIntegrity)
+}
 
-	// try to unzip it, but it's ok if that fails, it might be regular json
-	var gunzipppedBytes []byte
-	gzReader, err := gzip.NewReader(file)
-	if err == nil {
-		defer gzReader.Close()
-		gunzipppedBytes, err = ioutil.ReadAll(gzReader)
+/*
+openNetworkFile opens a saved network and notes its format in the report. JSON
+that was gzipped comes out of the reader unzipped.
+*/
+func openNetworkFile(filepath string, report *MigrationReport) (reader *bufio.Reader, closeFile func(), err error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader = bufio.NewReader(file)
+	if magic, _ := reader.Peek(len(nurMagic)); string(magic) == nurMagic {
+		report.Format = "binary"
+		return reader, func() { file.Close() }, nil
+	}
+	if magic, _ := reader.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		report.Format = "gzip-json"
+		gzReader, err := gzip.NewReader(reader)
 		if err != nil {
-			return network, err
+			file.Close()
+			return nil, nil, err
 		}
+		return bufio.NewReader(gzReader), func() { gzReader.Close(); file.Close() }, nil
 	}
+	report.Format = "json"
+	return reader, func() { file.Close() }, nil
+}
 
-	var jsonBytes []byte
-	if len(gunzipppedBytes) > 0 {
-		jsonBytes = gunzipppedBytes
-	} else {
-		err = file.Close()
+/*
+decodeJSONStream reads a network saved as JSON one cell and one synapse at a
+time. The other fields are small, so they are gathered up and decoded together
+at the end.
+*/
+func (network *Network) decodeJSONStream(r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	if err := expectJSONDelim(decoder, '{'); err != nil {
+		return err
+	}
+	rest := make(map[string]json.RawMessage)
+	for decoder.More() {
+		token, err := decoder.Token()
 		if err != nil {
-			return network, err
+			return err
+		}
+		key, _ := token.(string)
+		switch key {
+		case "Cells":
+			err = decodeJSONArray(decoder, func() error {
+				var cell *Cell
+				err := decoder.Decode(&cell)
+				network.Cells = append(network.Cells, cell)
+				return err
+			})
+		case "Synapses":
+			err = decodeJSONArray(decoder, func() error {
+				var synapse *Synapse
+				err := decoder.Decode(&synapse)
+				network.Synapses = append(network.Synapses, synapse)
+				return err
+			})
+		default:
+			var raw json.RawMessage
+			err = decoder.Decode(&raw)
+			rest[key] = raw
 		}
-		jsonBytes, err = ioutil.ReadFile(filepath)
 		if err != nil {
-			return network, err
+			return err
 		}
 	}
+	if err := expectJSONDelim(decoder, '}'); err != nil {
+		return err
+	}
 
-	err = json.Unmarshal(jsonBytes, network)
+	// without a version, the network is older than versioning
+	if _, ok := rest["SchemaVersion"]; !ok {
+		network.SchemaVersion = 0
+	}
+	buf, err := json.Marshal(rest)
 	if err != nil {
-		return network, err
+		return err
+	}
+	return json.Unmarshal(buf, network)
+}
+
+func expectJSONDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("Expected %s in network JSON, found %v", delim, token)
+	}
+	return nil
+}
+
+// decodeJSONArray calls each for every element of an array, which may be null.
+func decodeJSONArray(decoder *json.Decoder, each func() error) error {
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return err
 	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("Expected [ in network JSON, found %v", token)
+	}
+	for decoder.More() {
+		if err = each(); err != nil {
+			return err
+		}
+	}
+	return expectJSONDelim(decoder, ']')
+}
+
+/*
+afterLoad readies a network that was just read, whatever the format, and makes
+sure it can be used. The source is for error messages.
+*/
+func (network *Network) afterLoad(source string, checkIntegrity bool) error {
+	if network.Laws == nil {
+		return fmt.Errorf("Cannot load network without laws from %s", source)
+	}
+	if err := network.Laws.Validate(); err != nil {
+		return fmt.Errorf("Cannot load network with bad laws from %s: %s", source, err)
+	}
+	if _, err := NewPlasticityRule(network.Laws.PlasticityRule); err != nil {
+		return fmt.Errorf("Cannot load network with bad laws from %s: %s", source, err)
+	}
+	// restart the generator from the saved seed, so every load of the same file
+	// replays the same random numbers; networks saved without a seed keep the
+	// one from NewNetwork
+	network.SetSeed(network.Seed)
 
 	for _, synapse := range network.Synapses {
 		if synapse == nil {
@@ -336,11 +619,16 @@ func LoadNetworkFromFile(filepath string) (*Network, error) {
 			continue
 		}
 		cell.Network = network
+		network.markActive(cell)
 	}
 
+	if !checkIntegrity {
+		return nil
+	}
 	if ok, report := CheckIntegrity(network); !ok {
 		report.Print()
-		return network, fmt.Errorf("Cannot load network with bad integrity from file %s", filepath)
+		log.Println("Networks with bad integrity can be repaired with `nt repair`")
+		return fmt.Errorf("Cannot load network with bad integrity from %s", source)
 	}
-	return network, nil
+	return nil
 }
@@ -35,6 +35,43 @@ func Test_NewNetwork(t *testing.T) {
 	})
 }
 
+func Test_NetworkSeed(t *testing.T) {
+	grow := func(seed int64) *Network {
+		network := NewNetwork()
+		network.SetSeed(seed)
+		network.Grow(60, 10, 60)
+		return network
+	}
+
+	t.Run("two networks with the same seed grow the same", func(t *testing.T) {
+		n1, err := grow(11).ToJSON()
+		assert.NoError(t, err)
+		n2, err := grow(11).ToJSON()
+		assert.NoError(t, err)
+		assert.Equal(t, n1, n2)
+	})
+
+	t.Run("networks with different seeds grow differently", func(t *testing.T) {
+		n1, err := grow(11).ToJSON()
+		assert.NoError(t, err)
+		n2, err := grow(12).ToJSON()
+		assert.NoError(t, err)
+		assert.NotEqual(t, n1, n2)
+	})
+
+	t.Run("the seed is saved with the network and reseeds it on load", func(t *testing.T) {
+		network := grow(99)
+		err := network.SaveToFile("_network_seed.test.json")
+		assert.NoError(t, err)
+		net2, err := LoadNetworkFromFile("_network_seed.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(99), net2.Seed)
+
+		network.SetSeed(99)
+		assert.Equal(t, network.RandomCellKey(), net2.RandomCellKey())
+	})
+}
+
 func Test_BasicNetworkFiring(t *testing.T) {
 	t.Run("does not panic during forced FireActionPotential", func(t *testing.T) {
 		network := NewNetwork()
@@ -122,6 +159,47 @@ func Test_NetworkSerialization(t *testing.T) {
 			"Cannot load network with bad integrity from file "+filepath)
 	})
 
+	t.Run("saves the laws with the network and loads them back", func(t *testing.T) {
+		before()
+		network.Laws.SynapseLearnRate = 7
+		network.Laws.NoiseRatio = 0.3
+		err := network.SaveToFile("_network_laws.test.json")
+		assert.NoError(t, err)
+		net2, err := LoadNetworkFromFile("_network_laws.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, int16(7), net2.Laws.SynapseLearnRate)
+		assert.Equal(t, 0.3, net2.Laws.NoiseRatio)
+	})
+
+	t.Run("saves synapse delays with the network", func(t *testing.T) {
+		before()
+		synapse.Delay = 4
+		err := network.SaveToFile("_network_delay.test.json")
+		assert.NoError(t, err)
+		net2, err := LoadNetworkFromFile("_network_delay.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(4), net2.GetSyn(synapse.ID).Delay)
+	})
+
+	t.Run("loading a network saved without laws uses the default laws", func(t *testing.T) {
+		filepath := "_network_nolaws.test.json"
+		err := ioutil.WriteFile(filepath, []byte(`{"Cells":[],"Synapses":[]}`), os.ModePerm)
+		assert.NoError(t, err)
+		net2, err := LoadNetworkFromFile(filepath)
+		assert.NoError(t, err)
+		assert.Equal(t, laws.DefaultLaws(), net2.Laws)
+	})
+
+	t.Run("loading a network with invalid laws returns an error", func(t *testing.T) {
+		before()
+		network.Laws.FiringIterationsPerSample = 0
+		filepath := "_network_badlaws.test.json"
+		err := network.SaveToFile(filepath)
+		assert.NoError(t, err)
+		_, err = LoadNetworkFromFile(filepath)
+		assert.Error(t, err)
+	})
+
 	t.Run("loading a non-existant file network returns an error", func(t *testing.T) {
 		_, err := LoadNetworkFromFile("/kasdjfkk/asdkfjdsk")
 		assert.Error(t, err)
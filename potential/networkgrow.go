@@ -1,16 +1,18 @@
 package potential
 
 import (
-	"sync"
-
-	"github.com/ruffrey/nurtrace/laws"
 	"log"
+	"math"
 )
 
 // All methods on a network that relate to growing are here.
 
 /*
 Grow is a general growth that encompasses all growth methods.
+
+With the `DalesLaw` law on, `InhibitoryCellRatio` of the new neurons are
+inhibitory and the rest excitatory, and every synapse grown has the sign of the
+cell it comes from.
 */
 func (network *Network) Grow(neuronsToAdd, synapsesPerNewNeuron, synapsesToAdd int) {
 	someSynapses := synapsesPerNewNeuron / 4
@@ -22,14 +24,15 @@ func (network *Network) Grow(neuronsToAdd, synapsesPerNewNeuron, synapsesToAdd i
 				"totalSynapses=", len(network.Synapses))
 		}
 		from := network.RandomCellKey()
-		var to CellID
-		for {
-			to = network.RandomCellKey()
-			if to != from {
-				break
-			}
+		to := from
+		// give up eventually, in case the cell's region only connects to itself
+		for tries := 0; to == from && tries < 100; tries++ {
+			to = network.randomTargetFor(from)
 		}
-		network.GrowPathBetween(from, to, laws.ComputedSynapsesPerCell)
+		if to == from {
+			continue
+		}
+		network.GrowPathBetween(from, to, network.Laws.ComputedSynapsesPerCell())
 	}
 }
 
@@ -49,58 +52,45 @@ end dendrites.
 number of synapses that connect from startCell's tree to endCell.
 
 After `maxDepth`, if there are not minSynapses, we create synapses at that layer.
+Created synapses get a delay from the network's delay laws.
 */
 func (network *Network) GrowPathBetween(startCell, endCell CellID, minSynapses int) (synapsesToEnd map[SynapseID]bool, synapsesAdded map[SynapseID]bool) {
 	// these are the synapses we found that are on the path from the startCell,
 	// and attach directly to an endCell at the dendrite
 	synapsesToEnd = make(map[SynapseID]bool)
-	var synapsesToEndMux sync.Mutex
 	// any new synapses we create if there are not enough in the network that attach
 	// to the end cell
 	synapsesAdded = make(map[SynapseID]bool)
 
-	const maxDepth = laws.MaxDepthFromInputToOutput
-	var depthReached uint8
+	maxDepth := int(network.Laws.MaxDepthFromInputToOutput)
 
-	mux := sync.Mutex{}
-	var wg sync.WaitGroup
-	ch := make(chan SynapseID)
 	alreadyWalked := make(map[CellID]bool)
 	lastDepth := make(map[CellID]bool)
 
-	// walk traverses the axons and see if any synapse leads to the end cell.
-	// hops is the layer we are on, *copied* on pass.
-	// this is a fan-out kind of traversal through a tree of cells and synapses.
-	// Note: need to fully declare it before assigning it, apparently because the
-	// runtime needs this to compile a recursive function.
-	var walk func(cellID CellID, currentDepth uint8)
-	walk = func(cellID CellID, currentDepth uint8) {
-		mux.Lock()
-		synapsesToEndMux.Lock()
-		if currentDepth > depthReached {
-			depthReached = currentDepth
-			lastDepth = make(map[CellID]bool)
-		}
-		lastDepth[cellID] = true
-		totalSynapsesFound := len(synapsesToEnd)
-		synapsesToEndMux.Unlock()
-		if currentDepth > maxDepth || totalSynapsesFound >= minSynapses {
-			mux.Unlock()
-			return
+	// Traverse the axons one layer at a time and see if any synapse leads to the
+	// end cell. This is a fan-out kind of traversal through a tree of cells and
+	// synapses. Cells and synapses are walked in ID order, so the same network
+	// always grows the same path.
+	layer := []CellID{startCell}
+	for depth := 0; len(layer) > 0; depth++ {
+		lastDepth = make(map[CellID]bool)
+		for _, cellID := range layer {
+			lastDepth[cellID] = true
 		}
-		if _, already := alreadyWalked[cellID]; already {
-			mux.Unlock()
-			return
+		if depth > maxDepth || len(synapsesToEnd) >= minSynapses {
+			break
 		}
-		alreadyWalked[cellID] = true
-		mux.Unlock()
-
-		wg.Add(1)
-		// look at the next cells in the axon chain from this one, to see
-		// if any are the endCell then send it down the channel.
-		go func() {
-			axonSynapses := network.GetCell(cellID).AxonSynapses
-			for axonSynapseID := range axonSynapses {
+
+		var nextLayer []CellID
+		for _, cellID := range layer {
+			if alreadyWalked[cellID] {
+				continue
+			}
+			alreadyWalked[cellID] = true
+
+			// look at the next cells in the axon chain from this one, to see
+			// if any are the endCell.
+			for _, axonSynapseID := range sortedSynapseIDs(network.GetCell(cellID).AxonSynapses) {
 				s := network.GetSyn(axonSynapseID)
 				receiverCellID := s.ToNeuronDendrite
 
@@ -108,53 +98,44 @@ func (network *Network) GrowPathBetween(startCell, endCell CellID, minSynapses i
 				// We also walk the axons of this cell.
 				if s.Millivolts > 0 {
 					if receiverCellID == endCell {
-						ch <- axonSynapseID
+						synapsesToEnd[axonSynapseID] = true
 					}
-					walk(receiverCellID, currentDepth+1)
+					nextLayer = append(nextLayer, receiverCellID)
 				}
 			}
-			wg.Done()
-		}()
-	}
-
-	// receive the connected synapses.
-	// channel is closed upstream when we reach the end
-	go func() {
-		walk(startCell, 0)
-		wg.Wait()
-		close(ch)
-	}()
-
-	for synapseToOutputCell := range ch {
-		// log.Println("synapseToOutputCell", synapseToOutputCell)
-		synapsesToEndMux.Lock()
-		synapsesToEnd[synapseToOutputCell] = true
-		synapsesToEndMux.Unlock()
+		}
+		layer = nextLayer
 	}
 
-	needSynapses := minSynapses - len(synapsesToEnd) // out of multithreading now; no mutex
+	needSynapses := minSynapses - len(synapsesToEnd)
 	if needSynapses > 0 {
-		lastCell := randCellFromMap(lastDepth)
+		// inhibitory cells cannot excite the next cell on the path
+		lastCell := network.randCellFromMap(withoutInhibitory(network, lastDepth))
+		// filtered and sorted once, since every other pick comes from these
+		var walked []CellID
+		if needSynapses > 1 {
+			walked = sortedCellIDs(withoutInhibitory(network, alreadyWalked))
+		}
 		alt := true
 		for i := 0; i < needSynapses-1; i++ {
 			var intermediary CellID
 			if alt {
-				intermediary = randCellFromMap(alreadyWalked)
+				intermediary = network.randCellFrom(walked)
 			} else {
-				intermediary = network.RandomCellKey()
+				intermediary = network.randomNonInhibitoryTargetFor(lastCell)
 			}
 			alt = !alt
 
-			newLinkingSynapse := network.linkCells(lastCell, intermediary)
+			newLinkingSynapse := network.growSynapse(lastCell, intermediary)
 
 			synapsesAdded[newLinkingSynapse.ID] = true
 
 			lastCell = intermediary
 		}
 
-		newLinkingSynapse := network.linkCells(lastCell, endCell)
+		newLinkingSynapse := network.growSynapse(lastCell, endCell)
 		synapsesAdded[newLinkingSynapse.ID] = true
-		newLinkingSynapse.Millivolts = int16(laws.CellFireVoltageThreshold)
+		newLinkingSynapse.Millivolts = network.GetCell(lastCell).Type.signed(int16(network.Laws.CellFireVoltageThreshold))
 	}
 
 	// Reinforce the path between expected input and output.
@@ -163,13 +144,46 @@ func (network *Network) GrowPathBetween(startCell, endCell CellID, minSynapses i
 	// round of code to do anything. Because the new pathways
 	// generated in the block above did not necessarily fire.
 	goodSynapses := backwardTraceFirings(network, endCell, startCell)
-	for synapseID := range goodSynapses {
+	for _, synapseID := range sortedSynapseIDs(goodSynapses) {
 		network.Synapses[synapseID].reinforce()
 	}
 
 	return synapsesToEnd, synapsesAdded
 }
 
+/*
+withoutInhibitory returns the cells in the map that are not inhibitory. If they
+all are, it returns the map as it is, so there is still something to pick from.
+*/
+func withoutInhibitory(network *Network, cellMap map[CellID]bool) map[CellID]bool {
+	filtered := make(map[CellID]bool)
+	for cellID := range cellMap {
+		if cell := network.GetCell(cellID); cell != nil && cell.Type != InhibitoryCell {
+			filtered[cellID] = true
+		}
+	}
+	if len(filtered) == 0 {
+		return cellMap
+	}
+	return filtered
+}
+
+/*
+randomNonInhibitoryTargetFor is randomTargetFor, trying a few more times if it
+lands on an inhibitory cell.
+*/
+func (network *Network) randomNonInhibitoryTargetFor(fromCellID CellID) CellID {
+	cellID := network.randomTargetFor(fromCellID)
+	for tries := 0; tries < 10; tries++ {
+		cell := network.GetCell(cellID)
+		if cell == nil || cell.Type != InhibitoryCell {
+			break
+		}
+		cellID = network.randomTargetFor(fromCellID)
+	}
+	return cellID
+}
+
 /*
 GrowRandomNeurons will randomly add neurons with the default number of synapses to the network.
 */
@@ -183,32 +197,62 @@ func (network *Network) GrowRandomNeurons(neuronsToAdd, synapsesPerNeuron int) {
 		cell := NewCell(network)
 		addedNeurons = append(addedNeurons, cell)
 	}
+	network.assignCellTypes(addedNeurons)
 
 	// Now we add the default number of synapses to our new neurons, with random other neurons.
-	// Create the synapse, then choose a random cell from the network. This cell
-	// will be the sender to the random cell.
 	for _, cell := range addedNeurons {
-		for i := 0; i < synapsesPerNeuron; {
-			ix := network.RandomCellKey()
-			otherCell := network.Cells[ix]
-			if cell.ID == otherCell.ID {
-				// try again
-				continue
-			}
+		network.growSynapsesFrom(cell.ID, synapsesPerNeuron)
+	}
+}
 
-			network.linkCells(cell.ID, otherCell.ID)
-			i++
+/*
+growSynapsesFrom grows synapses from a cell onto random other cells, following
+the connections of the cell's region. The new cell will be the sender to the
+random cells.
+*/
+func (network *Network) growSynapsesFrom(cellID CellID, synapses int) {
+	// give up eventually, in case there is nowhere else to grow
+	for i, tries := 0, 0; i < synapses && tries < synapses*100; tries++ {
+		otherCellID := network.randomTargetFor(cellID)
+		if cellID == otherCellID || !network.CellExists(otherCellID) {
+			// try again
+			continue
+		}
+
+		network.growSynapse(cellID, otherCellID)
+		i++
+	}
+}
+
+/*
+assignCellTypes makes `InhibitoryCellRatio` of the cells inhibitory and the rest
+excitatory, when the `DalesLaw` law is on. Which ones are inhibitory is random.
+Otherwise the cells stay mixed.
+*/
+func (network *Network) assignCellTypes(cells []*Cell) {
+	if !network.Laws.DalesLaw {
+		return
+	}
+	inhibitory := int(math.Round(float64(len(cells)) * network.Laws.InhibitoryCellRatio))
+	for i, ix := range network.rng.Perm(len(cells)) {
+		if i < inhibitory {
+			cells[ix].Type = InhibitoryCell
+		} else {
+			cells[ix].Type = ExcitatoryCell
 		}
 	}
 }
 
 /*
 GrowRandomSynapses adds the specified number of synapses haphazardly to the network.
+Each gets a delay from the network's delay laws. Cells in a Region grow where its
+connections say.
 */
 func (network *Network) GrowRandomSynapses(synapsesToAdd int) {
-	for i := 0; i < synapsesToAdd; {
+	// give up eventually, in case there is nowhere else to grow
+	for i, tries := 0, 0; i < synapsesToAdd && tries < synapsesToAdd*100; tries++ {
 		senderIx := network.RandomCellKey()
-		receiverIx := network.RandomCellKey()
+		receiverIx := network.randomTargetFor(senderIx)
 		sender := network.Cells[senderIx]
 		receiver := network.Cells[receiverIx]
 		// Thy cell shannot activate thyself
@@ -216,7 +260,39 @@ func (network *Network) GrowRandomSynapses(synapsesToAdd int) {
 			continue
 		}
 
-		network.linkCells(sender.ID, receiver.ID)
+		network.growSynapse(sender.ID, receiver.ID)
 		i++
 	}
 }
+
+/*
+growSynapse links two cells with a new synapse that gets a conduction delay
+from the network's delay laws.
+*/
+func (network *Network) growSynapse(fromCellID CellID, toCellID CellID) *Synapse {
+	synapse := network.linkCells(fromCellID, toCellID)
+	synapse.Delay = network.randomSynapseDelay()
+	return synapse
+}
+
+/*
+randomSynapseDelay picks a delay between `SynapseDelayMin` and `SynapseDelayMax` using
+the `SynapseDelayDistribution` law.
+*/
+func (network *Network) randomSynapseDelay() uint8 {
+	min := int(network.Laws.SynapseDelayMin)
+	max := int(network.Laws.SynapseDelayMax)
+	if min == max {
+		return uint8(min)
+	}
+	if network.Laws.SynapseDelayDistribution == "exponential" {
+		// mean of a quarter of the range, so long delays are rare
+		mean := float64(max-min) / 4
+		delay := min + int(network.rng.ExpFloat64()*mean)
+		if delay > max {
+			delay = max
+		}
+		return uint8(delay)
+	}
+	return uint8(network.randomIntBetween(min, max))
+}
@@ -209,3 +209,74 @@ func Test_GrowPathBetween_Integrity(t *testing.T) {
 
 	})
 }
+
+func Test_GrowSynapseDelays(t *testing.T) {
+	t.Run("grown synapses get delays inside the configured range", func(t *testing.T) {
+		for _, distribution := range []string{"uniform", "exponential"} {
+			network := NewNetwork()
+			network.Laws.SynapseDelayMin = 2
+			network.Laws.SynapseDelayMax = 6
+			network.Laws.SynapseDelayDistribution = distribution
+			network.GrowRandomNeurons(20, 3)
+			network.GrowRandomSynapses(50)
+
+			for _, s := range network.Synapses {
+				assert.True(t, s.Delay >= 2 && s.Delay <= 6, distribution)
+			}
+		}
+	})
+	t.Run("default laws grow synapses without delay", func(t *testing.T) {
+		network := NewNetwork()
+		network.GrowRandomNeurons(10, 2)
+		network.GrowRandomSynapses(10)
+		for _, s := range network.Synapses {
+			assert.Equal(t, uint8(0), s.Delay)
+		}
+	})
+}
+
+func Test_GrowCellTypes(t *testing.T) {
+	t.Run("cells stay mixed without Dale's law", func(t *testing.T) {
+		network := NewNetwork()
+		network.GrowRandomNeurons(20, 3)
+		for _, cell := range network.Cells {
+			assert.Equal(t, MixedCell, cell.Type)
+		}
+	})
+	t.Run("Dale's law makes the inhibitory ratio of cells inhibitory and synapses follow their cell", func(t *testing.T) {
+		network := NewNetwork()
+		network.Laws.DalesLaw = true
+		network.Laws.InhibitoryCellRatio = 0.25
+		network.Grow(40, 12, 100)
+
+		counts := make(map[CellType]int)
+		for _, cell := range network.Cells {
+			counts[cell.Type]++
+		}
+		assert.Equal(t, 10, counts[InhibitoryCell])
+		assert.Equal(t, 30, counts[ExcitatoryCell])
+
+		for _, synapse := range network.Synapses {
+			fromType := network.GetCell(synapse.FromNeuronAxon).Type
+			if fromType == ExcitatoryCell {
+				assert.True(t, synapse.Millivolts >= 0)
+			} else {
+				assert.True(t, synapse.Millivolts <= 0)
+			}
+		}
+		ok, report := CheckIntegrity(network)
+		assert.True(t, ok, report)
+	})
+	t.Run("reinforcing keeps the sign of the cell type", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		a.Type = InhibitoryCell
+		b := NewCell(network)
+		synapse := network.linkCells(a.ID, b.ID)
+		synapse.Millivolts = network.Laws.ActualSynapseMin() + 1
+		newSynapseID := synapse.reinforce()
+		assert.True(t, network.GetSyn(newSynapseID).Millivolts < 0)
+		ok, report := CheckIntegrity(network)
+		assert.True(t, ok, report)
+	})
+}
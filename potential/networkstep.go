@@ -2,8 +2,8 @@ package potential
 
 import (
 	"math"
-
-	"github.com/ruffrey/nurtrace/laws"
+	"sort"
+	"sync"
 )
 
 /*
@@ -34,80 +34,409 @@ synapse happened to fire first (random based on cpu factors). It is
 not clear that this is the best solution, but applying synapse voltages
 immediately did not appear to lead to a usable network.
 
-When a cell fires, we stop its activation for the next step, much like a real
-neuron will go through a refractory period after it fires.
+When a cell fires, we stop its activation for its refractory period (the
+`CellRefractorySteps` law, by default the next step), much like a real neuron.
+Its threshold may also go up, and recover over the following steps, so cells
+that fire all the time get harder to fire. The network's
+PlasticityRule learns from which cells fired - by default, the synapses that made
+a cell fire are reinforced, and the synapses onto cells that did not fire are
+weakened. Idle synapses decay every `SynapseDecayInterval` steps.
+
+Step is event driven. It only visits the synapses that were queued with
+AddSynapseToNextStep, and only the cells that are not resting. On large
+networks, very few of either are active at once.
+
+Any StepObservers on the network are told what was delivered and what fired.
 */
 func (network *Network) Step() (hasMore bool) {
 	if network.Disabled {
 		return false
 	}
+	network.steps++
+
+	// delayed synapses that are due join this round
+	network.deliverPendingSynapses()
+
+	// Take the queue for this round. Anything queued while firing goes to the
+	// next round. Sorting keeps the tallies in the same order every time.
+	due := network.nextSynapses
+	network.nextSynapses = nil
+	sort.Slice(due, func(i, j int) bool { return due[i] < due[j] })
+
+	nextCellResets := network.tallyAndFire(due)
+
+	// Only cells that are not at rest need to move toward resting.
+	network.refractoryBookkeeping(nextCellResets)
+	for _, observer := range network.observers {
+		observer.CellsFired(network, network.lastFired)
+	}
+
+	if interval := network.Laws.SynapseDecayInterval; interval > 0 {
+		network.stepsSinceDecay++
+		if network.stepsSinceDecay >= interval {
+			network.stepsSinceDecay = 0
+			network.DecaySynapses()
+		}
+	}
+
+	hasMore = len(nextCellResets) > 0 || network.totalPending > 0
+
+	return hasMore
+}
+
+/*
+tallyAndFire applies the voltage of the due synapses to their cells, then fires the
+cells that reached the threshold. Returns the cells that fired.
+
+Synapses onto cells in their refractory period are queued again for the next round.
+
+With StepWorkers, the synapses are split into shards that are tallied in parallel,
+and then which cells reach their threshold is decided in parallel. Firing the cells
+and learning from them changes the network, so that still happens in ID order on
+this goroutine, which keeps it the same as a single worker.
+*/
+func (network *Network) tallyAndFire(due []SynapseID) (nextCellResets map[CellID]bool) {
+	nextCellResets = make(map[CellID]bool) // these cells get fired next
+
+	voltageTallies, tallyOrder := network.tally(due)
+
+	// see if any cells fired, and let the plasticity rule learn from it
+	sort.Slice(tallyOrder, func(i, j int) bool { return tallyOrder[i] < tallyOrder[j] })
+	fires := network.decideFiring(voltageTallies, tallyOrder)
+
+	if len(network.observers) > 0 {
+		var delivered []SynapseID
+		for _, cellID := range tallyOrder {
+			delivered = append(delivered, voltageTallies[cellID].synapses...)
+		}
+		for _, observer := range network.observers {
+			observer.SynapsesDelivered(network, delivered)
+		}
+	}
+
+	rule := network.plasticityRule()
+	for i, cellID := range tallyOrder {
+		fg := voltageTallies[cellID]
+		network.activeCells[cellID] = true
+		if !fires[i] {
+			rule.Tallied(network, cellID, fg.synapses, false)
+			continue
+		}
+
+		// It should fire.
+
+		network.GetCell(cellID).FireActionPotential()
+		nextCellResets[cellID] = true
+
+		rule.Tallied(network, cellID, fg.synapses, true)
+	}
+
+	return nextCellResets
+}
+
+// minSynapsesPerShard keeps small steps from paying for goroutines they do not need.
+const minSynapsesPerShard = 256
+
+/*
+stepShards is how many pieces to split n things into for the step workers.
+*/
+func (network *Network) stepShards(n int) int {
+	shards := network.StepWorkers
+	if most := n / minSynapsesPerShard; shards > most {
+		shards = most
+	}
+	if shards < 1 {
+		return 1
+	}
+	return shards
+}
+
+/*
+runShards calls work for each of the shards of n things, in parallel when there
+is more than one shard. Each call gets the range it is responsible for.
+*/
+func runShards(n, shards int, work func(shard, from, to int)) {
+	if shards == 1 {
+		work(0, 0, n)
+		return
+	}
+	size := (n + shards - 1) / shards
+	var wg sync.WaitGroup
+	for shard := 0; shard < shards; shard++ {
+		from := shard * size
+		to := from + size
+		if to > n {
+			to = n
+		}
+		wg.Add(1)
+		go func(shard, from, to int) {
+			work(shard, from, to)
+			wg.Done()
+		}(shard, from, to)
+	}
+	wg.Wait()
+}
+
+/*
+shardTally is the voltage one shard of synapses delivers to each cell.
+*/
+type shardTally struct {
+	groups map[CellID]*firingGroup
+	order  []CellID
+	// synapses onto cells in their refractory period
+	retry []SynapseID
+}
+
+/*
+tally adds up the voltage the due synapses deliver to each cell, on top of the
+cell's voltage. tallyOrder is the cells in the order they first got voltage.
+
+The due synapses are sorted, and the shards are contiguous pieces of them that
+are reduced in order, so each firing group lists its synapses in the same order
+no matter how many workers there are.
+*/
+func (network *Network) tally(due []SynapseID) (voltageTallies map[CellID]*firingGroup, tallyOrder []CellID) {
+	// a synapse has to be in only one shard
+	due = uniqueSorted(due)
+	shards := network.stepShards(len(due))
+	tallies := make([]shardTally, shards)
+	runShards(len(due), shards, func(shard, from, to int) {
+		tallies[shard] = network.tallyShard(due[from:to])
+	})
+
+	voltageTallies = make(map[CellID]*firingGroup)
+	for _, st := range tallies {
+		for _, cellID := range st.order {
+			sg := st.groups[cellID]
+			fg, seen := voltageTallies[cellID]
+			if !seen {
+				fg = newFiringGroup(network.GetCell(cellID))
+				voltageTallies[cellID] = fg
+				tallyOrder = append(tallyOrder, cellID)
+			}
+			fg.voltage += sg.voltage
+			fg.synapses = append(fg.synapses, sg.synapses...)
+		}
+		// try again when the cell can fire
+		network.nextSynapses = append(network.nextSynapses, st.retry...)
+	}
+	return voltageTallies, tallyOrder
+}
+
+// uniqueSorted drops repeats from a sorted list, in place.
+func uniqueSorted(ids []SynapseID) []SynapseID {
+	if len(ids) < 2 {
+		return ids
+	}
+	unique := ids[:1]
+	for _, id := range ids[1:] {
+		if id != unique[len(unique)-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
 
-	nextCellResets := make(map[CellID]bool) // these cells get fired next
-	voltageTallies := make(map[CellID]*firingGroup)
+/*
+tallyShard tallies one shard of the due synapses, starting every cell at zero.
 
-	// tally up all the synapse voltage results they will have on the cells
-	for _, syn := range network.Synapses {
-		if syn == nil || !syn.fireNextRound {
+Shards run at the same time. Nothing is added to or removed from the network
+while they do, so the slices are read without the mutexes, and each synapse is
+only in one shard.
+*/
+func (network *Network) tallyShard(due []SynapseID) (st shardTally) {
+	st.groups = make(map[CellID]*firingGroup)
+	for _, synapseID := range due {
+		if int(synapseID) >= len(network.Synapses) || network.Synapses[synapseID] == nil { // pruned while queued
+			continue
+		}
+		syn := network.Synapses[synapseID]
+		if !syn.fireNextRound {
 			continue
 		}
 
-		cellReceivingVoltage := network.GetCell(syn.ToNeuronDendrite)
+		cellReceivingVoltage := network.Cells[syn.ToNeuronDendrite]
 		if cellReceivingVoltage.activating { // do not fire cells in refractory period
+			st.retry = append(st.retry, synapseID)
 			continue
 		}
-		if _, seen := voltageTallies[cellReceivingVoltage.ID]; !seen {
-			voltageTallies[cellReceivingVoltage.ID] = newFiringGroup(cellReceivingVoltage)
+		fg, seen := st.groups[cellReceivingVoltage.ID]
+		if !seen {
+			fg = &firingGroup{synapses: make([]SynapseID, 0)}
+			st.groups[cellReceivingVoltage.ID] = fg
+			st.order = append(st.order, cellReceivingVoltage.ID)
 		}
-		fg := voltageTallies[cellReceivingVoltage.ID]
 		fg.voltage += int(syn.Millivolts)
 		// save the synapse for later so it can be boosted if the cell fires
 		fg.synapses = append(fg.synapses, syn.ID)
+		syn.delivered = true
+		syn.ActivationHistory++
 
 		// Reset this list of synapses now that we activated them. The next loop starts
 		// adding more for the next round.
 		syn.fireNextRound = false
 	}
-	// see if any cells fired
-	for cellID, fg := range voltageTallies {
-		cell := network.GetCell(cellID)
-		if fg.voltage < laws.CellFireVoltageThreshold {
+	return st
+}
+
+/*
+decideFiring returns whether each cell in tallyOrder reached its threshold. The
+cells that did not fire keep the voltage they were tallied to.
+*/
+func (network *Network) decideFiring(voltageTallies map[CellID]*firingGroup, tallyOrder []CellID) (fires []bool) {
+	fires = make([]bool, len(tallyOrder))
+	runShards(len(tallyOrder), network.stepShards(len(tallyOrder)), func(shard, from, to int) {
+		for i := from; i < to; i++ {
+			cellID := tallyOrder[i]
+			fg := voltageTallies[cellID]
+			cell := network.Cells[cellID]
+			if fg.voltage >= cell.threshold() {
+				fires[i] = true
+				continue
+			}
 			// prevent out of bounds voltage
-			cell.Voltage = int16(math.Max(float64(fg.voltage), float64(laws.ActualSynapseMin)))
-			continue
+			cell.Voltage = int16(math.Max(float64(fg.voltage), float64(network.Laws.ActualSynapseMin())))
 		}
+	})
+	return fires
+}
 
-		// It should fire.
-
-		cell.FireActionPotential()
-		nextCellResets[cellID] = true
+/*
+refractoryBookkeeping starts the refractory period on cells that just fired, counts
+it down on the cells already in it, and moves the rest of the active cells toward
+resting. Adapted thresholds recover a little every step.
 
-		// Reward the synapses that were involved in this cell firing.
-		for _, synapseID := range fg.synapses {
-			fromSynapse := network.GetSyn(synapseID)
-			fromSynapse.reinforce()
+Cells that are back at rest leave the active set.
+*/
+func (network *Network) refractoryBookkeeping(nextCellResets map[CellID]bool) {
+	fired := make([]CellID, 0, len(nextCellResets))
+	for cellID := range network.activeCells {
+		if !network.CellExists(cellID) { // pruned
+			delete(network.activeCells, cellID)
+			continue
 		}
-	}
-
-	// for the cells from the last step, make them fire-able again
-	for cellID, cell := range network.Cells {
-		if cell.activating {
+		cell := network.GetCell(cellID)
+		if nextCellResets[cellID] {
+			fired = append(fired, cellID)
+			if steps := cell.refractorySteps(); steps > 0 {
+				cell.activating = true
+				cell.refractoryLeft = steps
+				continue
+			}
 			cell.postRefractoryReset()
-		} else if nextCellResets[CellID(cellID)] {
-			cell.activating = true
 		} else {
+			cell.recoverThreshold()
+		}
+
+		stillMoving := false
+		if cell.activating {
+			if cell.refractoryLeft > 1 {
+				cell.refractoryLeft--
+			} else {
+				cell.postRefractoryReset()
+			}
+		} else if !nextCellResets[cellID] {
+			before := cell.Voltage
 			cell.towardResting()
+			// towardResting stops moving very close to resting
+			stillMoving = cell.Voltage != before
+		}
+		if cell.activating || stillMoving || cell.isAdapted() {
+			continue
 		}
+		delete(network.activeCells, cellID)
 	}
-
-	hasMore = len(nextCellResets) > 0
-
-	return hasMore
+	sort.Slice(fired, func(i, j int) bool { return fired[i] < fired[j] })
+	network.lastFired = fired
 }
 
 /*
 AddSynapseToNextStep provides a reusable method for having a synapse get activated on the
 next step.
+
+Synapses with a Delay are scheduled that many steps later instead.
 */
 func (network *Network) AddSynapseToNextStep(id SynapseID) {
-	network.GetSyn(id).fireNextRound = true
+	synapse := network.GetSyn(id)
+	if synapse.Delay == 0 {
+		network.queueSynapse(synapse)
+		return
+	}
+	network.scheduleDelivery(id, synapse.Delay)
+}
+
+/*
+queueSynapse flags a synapse to fire on the next step, and queues it once.
+*/
+func (network *Network) queueSynapse(synapse *Synapse) {
+	if synapse.fireNextRound {
+		return
+	}
+	synapse.fireNextRound = true
+	network.nextSynapses = append(network.nextSynapses, synapse.ID)
+}
+
+/*
+markActive adds a cell to the set of cells that Step has to look after, because
+it is not at rest.
+*/
+func (network *Network) markActive(cell *Cell) {
+	if cell.activating || cell.Voltage != network.Laws.CellRestingVoltage || cell.isAdapted() {
+		network.activeCells[cell.ID] = true
+	}
+}
+
+/*
+FiredLastStep returns the IDs of the cells that fired on the most recent Step,
+in order.
+*/
+func (network *Network) FiredLastStep() []CellID {
+	return network.lastFired
+}
+
+// deliveryRingSize fits every possible uint8 delay.
+const deliveryRingSize = math.MaxUint8 + 1
+
+/*
+scheduleDelivery puts a synapse in the ring buffer so it fires `delay` steps after
+the next step.
+*/
+func (network *Network) scheduleDelivery(id SynapseID, delay uint8) {
+	if network.pendingDeliveries == nil {
+		network.pendingDeliveries = make([][]SynapseID, deliveryRingSize)
+	}
+	slot := (network.deliveryCursor + int(delay)) % deliveryRingSize
+	network.pendingDeliveries[slot] = append(network.pendingDeliveries[slot], id)
+	network.totalPending++
+}
+
+/*
+deliverPendingSynapses flags the synapses in the current ring slot to fire this round,
+and moves the ring forward by one step.
+*/
+func (network *Network) deliverPendingSynapses() {
+	if network.totalPending == 0 {
+		return
+	}
+	due := network.pendingDeliveries[network.deliveryCursor]
+	network.pendingDeliveries[network.deliveryCursor] = nil
+	network.deliveryCursor = (network.deliveryCursor + 1) % deliveryRingSize
+	network.totalPending -= len(due)
+
+	for _, id := range due {
+		// may have been pruned while it was in flight
+		if !network.SynExists(id) {
+			continue
+		}
+		network.queueSynapse(network.GetSyn(id))
+	}
+}
+
+/*
+clearPendingDeliveries drops all synapses that were still waiting on a delay.
+*/
+func (network *Network) clearPendingDeliveries() {
+	network.pendingDeliveries = nil
+	network.deliveryCursor = 0
+	network.totalPending = 0
 }
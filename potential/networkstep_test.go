@@ -1,6 +1,9 @@
 package potential
 
 import (
+	"math"
+	"math/rand"
+	"runtime"
 	"testing"
 
 	"github.com/ruffrey/nurtrace/laws"
@@ -20,8 +23,8 @@ func Test_NetworkStep(t *testing.T) {
 		network.linkCells(cell.ID, receiverCellA.ID)
 		network.linkCells(cell.ID, receiverCellB.ID)
 
-		network.GetSyn(0).fireNextRound = true
-		network.GetSyn(1).fireNextRound = true
+		network.AddSynapseToNextStep(0)
+		network.AddSynapseToNextStep(1)
 		// enough to fire next cell
 		network.GetSyn(0).Millivolts = int16(laws.CellFireVoltageThreshold)
 		network.GetSyn(1).Millivolts = int16(laws.CellFireVoltageThreshold)
@@ -83,3 +86,403 @@ func Test_NetworkStep(t *testing.T) {
 			"should not have resets on next Step")
 	})
 }
+
+func Test_NetworkStepWeakening(t *testing.T) {
+	t.Run("synapses onto a cell that did not fire are weakened", func(t *testing.T) {
+		network := NewNetwork()
+		network.Laws.SynapseWeakenRatio = 0.5
+		cell := NewCell(network)
+		receiver := NewCell(network)
+		s := network.linkCells(cell.ID, receiver.ID)
+		s.Millivolts = 400
+
+		cell.FireActionPotential()
+		network.Step()
+		assert.Equal(t, false, receiver.activating)
+		assert.Equal(t, int16(200), s.Millivolts)
+	})
+	t.Run("synapses that made a cell fire are reinforced, not weakened", func(t *testing.T) {
+		network := NewNetwork()
+		network.Laws.SynapseWeakenRatio = 0.5
+		cell := NewCell(network)
+		receiver := NewCell(network)
+		s := network.linkCells(cell.ID, receiver.ID)
+		s.Millivolts = 2000
+
+		cell.FireActionPotential()
+		network.Step()
+		assert.Equal(t, true, receiver.activating)
+		assert.Equal(t, 2000+network.Laws.SynapseLearnRate, s.Millivolts)
+	})
+	t.Run("a synapse weakened to zero is pruned during the step", func(t *testing.T) {
+		network := NewNetwork()
+		network.Laws.SynapseWeakenRatio = 0.5
+		cell := NewCell(network)
+		cell.Immortal = true
+		receiver := NewCell(network)
+		s := network.linkCells(cell.ID, receiver.ID)
+		s.Millivolts = 4
+
+		cell.FireActionPotential()
+		network.Step()
+		assert.False(t, network.SynExists(s.ID))
+		assert.False(t, network.CellExists(receiver.ID))
+		network.Step()
+		ok, report := CheckIntegrity(network)
+		assert.True(t, ok, report)
+	})
+	t.Run("idle synapses decay every SynapseDecayInterval steps", func(t *testing.T) {
+		network := NewNetwork()
+		network.Laws.SynapseDecayRatio = 0.5
+		network.Laws.SynapseDecayInterval = 3
+		s := network.linkCells(NewCell(network).ID, NewCell(network).ID)
+		s.Millivolts = 400
+
+		network.Step()
+		network.Step()
+		assert.Equal(t, int16(400), s.Millivolts)
+		network.Step()
+		assert.Equal(t, int16(200), s.Millivolts)
+	})
+}
+
+func Test_NetworkStepDelays(t *testing.T) {
+	t.Run("delayed synapse applies its voltage that many steps later", func(t *testing.T) {
+		network := NewNetwork()
+		cell := NewCell(network)
+		receiver := NewCell(network)
+		s := network.linkCells(cell.ID, receiver.ID)
+		s.Millivolts = laws.ActualSynapseMax
+		s.Delay = 2
+
+		cell.FireActionPotential()
+		assert.Equal(t, false, s.fireNextRound, "delayed synapse should not fire next round")
+
+		assert.Equal(t, true, network.Step(), "pending delivery should count as more")
+		assert.Equal(t, false, receiver.activating)
+		assert.Equal(t, true, network.Step())
+		assert.Equal(t, false, receiver.activating)
+		// third step is the next step plus a delay of two
+		network.Step()
+		assert.Equal(t, true, receiver.activating)
+	})
+	t.Run("zero delay fires on the next step like before", func(t *testing.T) {
+		network := NewNetwork()
+		cell := NewCell(network)
+		receiver := NewCell(network)
+		s := network.linkCells(cell.ID, receiver.ID)
+		s.Millivolts = laws.ActualSynapseMax
+
+		cell.FireActionPotential()
+		assert.Equal(t, true, s.fireNextRound)
+		network.Step()
+		assert.Equal(t, true, receiver.activating)
+	})
+	t.Run("synapse pruned while in flight is skipped", func(t *testing.T) {
+		network := NewNetwork()
+		cell := NewCell(network)
+		cell.Immortal = true
+		receiver := NewCell(network)
+		receiver.Immortal = true
+		s := network.linkCells(cell.ID, receiver.ID)
+		s.Delay = 1
+
+		cell.FireActionPotential()
+		network.PruneSynapse(s.ID)
+		network.Step()
+		network.Step()
+		assert.Equal(t, false, receiver.activating)
+		assert.Equal(t, 0, network.totalPending)
+	})
+	t.Run("ResetForTraining drops pending deliveries", func(t *testing.T) {
+		network := NewNetwork()
+		cell := NewCell(network)
+		receiver := NewCell(network)
+		s := network.linkCells(cell.ID, receiver.ID)
+		s.Delay = 5
+
+		cell.FireActionPotential()
+		assert.Equal(t, 1, network.totalPending)
+		network.ResetForTraining()
+		assert.Equal(t, 0, network.totalPending)
+		assert.Equal(t, false, network.Step())
+	})
+}
+
+/*
+stepFullScan is the original Step, which scans every synapse and every cell.
+It is kept here to prove the event driven Step fires the same way. It fires
+cells in map order, like it always did.
+*/
+func stepFullScan(network *Network) (hasMore bool) {
+	if network.Disabled {
+		return false
+	}
+	network.deliverPendingSynapses()
+	network.nextSynapses = nil // only the flags matter here
+
+	nextCellResets := make(map[CellID]bool)
+	voltageTallies := make(map[CellID]*firingGroup)
+
+	for _, syn := range network.Synapses {
+		if syn == nil || !syn.fireNextRound {
+			continue
+		}
+		cellReceivingVoltage := network.GetCell(syn.ToNeuronDendrite)
+		if cellReceivingVoltage.activating {
+			continue
+		}
+		if _, seen := voltageTallies[cellReceivingVoltage.ID]; !seen {
+			voltageTallies[cellReceivingVoltage.ID] = newFiringGroup(cellReceivingVoltage)
+		}
+		fg := voltageTallies[cellReceivingVoltage.ID]
+		fg.voltage += int(syn.Millivolts)
+		fg.synapses = append(fg.synapses, syn.ID)
+		syn.fireNextRound = false
+	}
+	for cellID, fg := range voltageTallies {
+		cell := network.GetCell(cellID)
+		if fg.voltage < network.Laws.CellFireVoltageThreshold {
+			cell.Voltage = int16(math.Max(float64(fg.voltage), float64(network.Laws.ActualSynapseMin())))
+			continue
+		}
+		cell.FireActionPotential()
+		nextCellResets[cellID] = true
+		for _, synapseID := range fg.synapses {
+			network.GetSyn(synapseID).reinforce()
+		}
+	}
+
+	for cellID, cell := range network.Cells {
+		if cell == nil {
+			continue
+		}
+		if cell.activating {
+			cell.postRefractoryReset()
+		} else if nextCellResets[CellID(cellID)] {
+			cell.activating = true
+		} else {
+			cell.towardResting()
+		}
+	}
+
+	return len(nextCellResets) > 0 || network.totalPending > 0
+}
+
+/*
+excitableNetwork grows a network with weights strong enough to keep firing.
+Weights are between -400 and maxMillivolts.
+*/
+func excitableNetwork(seed int64, cells, synapsesPerCell, maxMillivolts int) (*Network, []CellID) {
+	r := rand.New(rand.NewSource(seed))
+	network := NewNetwork()
+	network.GrowRandomNeurons(cells, synapsesPerCell)
+	for _, s := range network.Synapses {
+		s.Millivolts = int16(r.Intn(maxMillivolts+400) - 400)
+	}
+	var seedCells []CellID
+	for i := 0; i < cells/20+1; i++ {
+		seedCells = append(seedCells, CellID(r.Intn(cells)))
+	}
+	return network, seedCells
+}
+
+func activatingCells(network *Network) []CellID {
+	var fired []CellID
+	for _, cell := range network.Cells {
+		if cell.activating {
+			fired = append(fired, cell.ID)
+		}
+	}
+	return fired
+}
+
+func Test_NetworkStepRefractory(t *testing.T) {
+	// fires a on every step, and returns the steps b fired on
+	drive := func(network *Network, a, b *Cell, steps int) (firedOn []int) {
+		for i := 1; i <= steps; i++ {
+			a.FireActionPotential()
+			network.Step()
+			for _, cellID := range network.FiredLastStep() {
+				if cellID == b.ID {
+					firedOn = append(firedOn, i)
+				}
+			}
+		}
+		return firedOn
+	}
+	setup := func() (*Network, *Cell, *Cell) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		s := network.linkCells(a.ID, b.ID)
+		s.Millivolts = int16(network.Laws.CellFireVoltageThreshold * 2)
+		return network, a, b
+	}
+
+	t.Run("by default a cell can fire every other step", func(t *testing.T) {
+		network, a, b := setup()
+		assert.Equal(t, []int{1, 3, 5, 7}, drive(network, a, b, 8))
+	})
+	t.Run("the refractory period is configurable by law", func(t *testing.T) {
+		network, a, b := setup()
+		network.Laws.CellRefractorySteps = 3
+		assert.Equal(t, []int{1, 5}, drive(network, a, b, 8))
+		network.Laws.CellRefractorySteps = 0
+		network.ResetForTraining()
+		assert.Equal(t, []int{1, 2, 3, 4}, drive(network, a, b, 4))
+	})
+	t.Run("a cell can have its own refractory period", func(t *testing.T) {
+		network, a, b := setup()
+		b.RefractorySteps = 2
+		assert.Equal(t, []int{1, 4, 7}, drive(network, a, b, 8))
+	})
+	t.Run("the threshold goes up every time a cell fires and recovers", func(t *testing.T) {
+		network, a, b := setup()
+		network.Laws.CellRefractorySteps = 0
+		network.Laws.ThresholdAdaptation = 500
+		network.Laws.ThresholdRecoveryRatio = 0.5
+		base := network.Laws.CellFireVoltageThreshold
+
+		// the synapse is twice the threshold, so b fires until it has adapted
+		// past that, then it has to wait for it to recover
+		firedOn := drive(network, a, b, 10)
+		assert.Equal(t, []int{1, 2}, firedOn[:2])
+		assert.NotContains(t, firedOn, 3)
+		assert.True(t, len(firedOn) < 10)
+		assert.True(t, b.Threshold > base)
+
+		// with nothing firing it recovers all the way
+		for i := 0; i < 20; i++ {
+			network.Step()
+		}
+		assert.Equal(t, base, b.threshold())
+		assert.False(t, b.isAdapted())
+		assert.Empty(t, network.activeCells)
+	})
+	t.Run("the adapted threshold is saved and ResetForTraining recovers it", func(t *testing.T) {
+		network, a, b := setup()
+		network.Laws.ThresholdAdaptation = 300
+		drive(network, a, b, 1)
+		assert.Equal(t, network.Laws.CellFireVoltageThreshold+300, b.Threshold)
+
+		err := network.SaveToFile("_network_threshold.test.json")
+		assert.NoError(t, err)
+		net2, err := LoadNetworkFromFile("_network_threshold.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, b.Threshold, net2.GetCell(b.ID).Threshold)
+		assert.True(t, net2.activeCells[b.ID], "so it keeps recovering")
+
+		network.ResetForTraining()
+		assert.False(t, b.isAdapted())
+	})
+}
+
+func Test_StepMatchesFullScan(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		eventDriven, seedCells := excitableNetwork(seed, 300, 15, 1200)
+		fullScan := CloneNetwork(eventDriven)
+		firedEventDriven := make(FiringPattern)
+		firedFullScan := make(FiringPattern)
+
+		for i := 0; i < 60; i++ {
+			if i%4 == 0 {
+				for _, cellID := range seedCells {
+					eventDriven.GetCell(cellID).FireActionPotential()
+					fullScan.GetCell(cellID).FireActionPotential()
+				}
+			}
+			hasMoreA := eventDriven.Step()
+			hasMoreB := stepFullScan(fullScan)
+			assert.Equal(t, hasMoreB, hasMoreA, "seed=%d step=%d", seed, i)
+			assert.Equal(t, activatingCells(fullScan), activatingCells(eventDriven),
+				"seed=%d step=%d", seed, i)
+			assert.Equal(t, activatingCells(eventDriven), eventDriven.FiredLastStep(),
+				"seed=%d step=%d", seed, i)
+			for _, cellID := range activatingCells(eventDriven) {
+				firedEventDriven[cellID]++
+			}
+			for _, cellID := range activatingCells(fullScan) {
+				firedFullScan[cellID]++
+			}
+		}
+
+		// the firing order does not change which cells fire, or how often
+		assert.Equal(t, firedFullScan, firedEventDriven, "seed=%d", seed)
+		assert.Equal(t, len(fullScan.Synapses), len(eventDriven.Synapses), "seed=%d", seed)
+		for i, s := range fullScan.Synapses {
+			assert.Equal(t, s.Millivolts, eventDriven.Synapses[i].Millivolts, "seed=%d", seed)
+		}
+		for i, c := range fullScan.Cells {
+			assert.Equal(t, c.Voltage, eventDriven.Cells[i].Voltage, "seed=%d", seed)
+		}
+	}
+}
+
+func Test_ParallelStep(t *testing.T) {
+	t.Run("parallel and serial steps fire the same patterns and learn the same", func(t *testing.T) {
+		for seed := int64(1); seed <= 3; seed++ {
+			serial, seedCells := excitableNetwork(seed, 3000, 15, 1200)
+			serial.Laws.NoiseRatio = 0
+			parallel := CloneNetwork(serial)
+			parallel.StepWorkers = 4
+			seedPattern := make(FiringPattern)
+			for _, cellID := range seedCells {
+				seedPattern[cellID] = 1
+			}
+
+			for i := 0; i < 3; i++ {
+				serialFP := FireNetworkUntilDone(serial, seedPattern)
+				parallelFP := FireNetworkUntilDone(parallel, seedPattern)
+				assert.NotEmpty(t, serialFP)
+				assert.Equal(t, serialFP, parallelFP, "seed=%d run=%d", seed, i)
+			}
+
+			assert.Equal(t, len(serial.Synapses), len(parallel.Synapses), "seed=%d", seed)
+			for i, s := range serial.Synapses {
+				if s == nil {
+					assert.Nil(t, parallel.Synapses[i])
+					continue
+				}
+				assert.Equal(t, s.Millivolts, parallel.Synapses[i].Millivolts, "seed=%d", seed)
+				assert.Equal(t, s.ActivationHistory, parallel.Synapses[i].ActivationHistory, "seed=%d", seed)
+			}
+		}
+	})
+	t.Run("small steps stay on one goroutine", func(t *testing.T) {
+		network := NewNetwork()
+		network.StepWorkers = 8
+		assert.Equal(t, 1, network.stepShards(minSynapsesPerShard-1))
+		assert.Equal(t, 2, network.stepShards(minSynapsesPerShard*2))
+		assert.Equal(t, 8, network.stepShards(minSynapsesPerShard*100))
+	})
+}
+
+// benchmarkStep runs a large network where a small part of it is firing at once.
+func benchmarkStep(b *testing.B, step func(*Network) bool) {
+	network, seedCells := excitableNetwork(1, 10000, 20, 700)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%4 == 0 {
+			for _, cellID := range seedCells[:10] {
+				network.GetCell(cellID).FireActionPotential()
+			}
+		}
+		step(network)
+	}
+}
+
+func Benchmark_Step(b *testing.B) {
+	benchmarkStep(b, (*Network).Step)
+}
+
+func Benchmark_StepParallel(b *testing.B) {
+	benchmarkStep(b, func(network *Network) bool {
+		network.StepWorkers = runtime.NumCPU()
+		return network.Step()
+	})
+}
+
+func Benchmark_StepFullScan(b *testing.B) {
+	benchmarkStep(b, stepFullScan)
+}
@@ -0,0 +1,476 @@
+package potential
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+/*
+The binary .nur format is a network file, written and read a cell and a
+synapse at a time, so neither side holds a second copy of the network in
+memory. All integers are little endian, or varints as in `encoding/binary`.
+
+	header
+	  "NURT"              magic
+	  version             uint16, NurFormatVersion
+	  schema version      uvarint, the network's SchemaVersion - not in version 2
+	  laws                uvarint length, then the laws as JSON
+	  regions             uvarint length, then the regions as JSON
+	  seed                int64
+	  disabled            byte
+	  cell ID cursor      uvarint
+	  synapse ID cursor   uvarint
+	  cell slots          uvarint, including pruned cells
+	  synapse slots       uvarint, including pruned synapses
+	  checksum            uint32, CRC-32 (IEEE) of the header
+	cells, one per slot - the ID is the slot
+	  present             byte, 0 for a pruned cell and nothing else follows
+	  flags               byte, 1 immortal, 2 was fired
+	  type                byte
+	  voltage             varint
+	  threshold           varint
+	  refractory steps    byte
+	  tag                 uvarint length, then bytes
+	  region              uvarint length, then bytes
+	  axon synapses       uvarint count, then the IDs in order as uvarint gaps
+	  dendrite synapses   uvarint count, then the IDs in order as uvarint gaps
+	checksum              uint32, CRC-32 of the cells
+	synapses, one per slot - the ID is the slot
+	  present             byte, 0 for a pruned synapse and nothing else follows
+	  millivolts          varint
+	  from cell           uvarint
+	  to cell             uvarint
+	  activation history  uvarint
+	  delay               byte
+	checksum              uint32, CRC-32 of the synapses
+
+Laws and regions are JSON because they are small, and so laws added later can
+still be read by older versions.
+
+Version 2 files are from before networks had a schema version, so they are
+read as schema version 0 and migrated like any other old network.
+*/
+
+// nurMagic starts every binary network file.
+const nurMagic = "NURT"
+
+/*
+NurFormatVersion is the version of the binary network file format that
+WriteBinary writes.
+*/
+const NurFormatVersion uint16 = 3
+
+// nurFormatVersionUnversioned is the last version without a schema version.
+const nurFormatVersionUnversioned uint16 = 2
+
+// maxNurString keeps a corrupt length from allocating the whole machine.
+const maxNurString = 64 << 20
+
+const (
+	nurCellImmortal byte = 1 << iota
+	nurCellWasFired
+)
+
+/*
+nurEncoder writes to a buffered writer and keeps a running checksum of the
+section being written. The first error sticks, and later writes do nothing.
+*/
+type nurEncoder struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *nurEncoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(p)
+	e.crc.Write(p)
+}
+
+func (e *nurEncoder) byte(b byte) {
+	e.buf[0] = b
+	e.write(e.buf[:1])
+}
+
+func (e *nurEncoder) uvarint(x uint64) {
+	n := binary.PutUvarint(e.buf[:], x)
+	e.write(e.buf[:n])
+}
+
+func (e *nurEncoder) varint(x int64) {
+	n := binary.PutVarint(e.buf[:], x)
+	e.write(e.buf[:n])
+}
+
+func (e *nurEncoder) bytes(p []byte) {
+	e.uvarint(uint64(len(p)))
+	e.write(p)
+}
+
+func (e *nurEncoder) synapseIDs(synapses map[SynapseID]bool) {
+	e.uvarint(uint64(len(synapses)))
+	last := SynapseID(0)
+	for _, id := range sortedSynapseIDs(synapses) {
+		e.uvarint(uint64(id - last))
+		last = id
+	}
+}
+
+// checksum ends a section with its checksum, and starts the next one.
+func (e *nurEncoder) checksum() {
+	sum := e.crc.Sum32()
+	binary.LittleEndian.PutUint32(e.buf[:4], sum)
+	e.write(e.buf[:4])
+	e.crc.Reset()
+}
+
+/*
+WriteBinary writes the network in the binary .nur format, at NurFormatVersion.
+*/
+func (network *Network) WriteBinary(w io.Writer) error {
+	return network.writeBinary(w, NurFormatVersion)
+}
+
+// writeBinary writes a version of the format, so tests can write older files.
+func (network *Network) writeBinary(w io.Writer, version uint16) error {
+	if network.mapping != nil {
+		return errMappedReadOnly
+	}
+	e := &nurEncoder{w: bufio.NewWriter(w), crc: crc32.NewIEEE()}
+
+	lawsJSON, err := json.Marshal(network.Laws)
+	if err != nil {
+		return err
+	}
+	regionsJSON, err := json.Marshal(network.Regions)
+	if err != nil {
+		return err
+	}
+	e.write([]byte(nurMagic))
+	binary.LittleEndian.PutUint16(e.buf[:2], version)
+	e.write(e.buf[:2])
+	if version > nurFormatVersionUnversioned {
+		e.uvarint(uint64(network.SchemaVersion))
+	}
+	e.bytes(lawsJSON)
+	e.bytes(regionsJSON)
+	binary.LittleEndian.PutUint64(e.buf[:8], uint64(network.Seed))
+	e.write(e.buf[:8])
+	if network.Disabled {
+		e.byte(1)
+	} else {
+		e.byte(0)
+	}
+	e.uvarint(uint64(network.CellIDCursor))
+	e.uvarint(uint64(network.SynIDCursor))
+	e.uvarint(uint64(len(network.Cells)))
+	e.uvarint(uint64(len(network.Synapses)))
+	e.checksum()
+
+	for _, cell := range network.Cells {
+		if cell == nil { // pruned
+			e.byte(0)
+			continue
+		}
+		e.byte(1)
+		var flags byte
+		if cell.Immortal {
+			flags |= nurCellImmortal
+		}
+		if cell.WasFired {
+			flags |= nurCellWasFired
+		}
+		e.byte(flags)
+		e.byte(byte(cell.Type))
+		e.varint(int64(cell.Voltage))
+		e.varint(int64(cell.Threshold))
+		e.byte(cell.RefractorySteps)
+		e.bytes([]byte(cell.Tag))
+		e.bytes([]byte(cell.Region))
+		e.synapseIDs(cell.AxonSynapses)
+		e.synapseIDs(cell.DendriteSynapses)
+	}
+	e.checksum()
+
+	for _, synapse := range network.Synapses {
+		if synapse == nil { // pruned
+			e.byte(0)
+			continue
+		}
+		e.byte(1)
+		e.varint(int64(synapse.Millivolts))
+		e.uvarint(uint64(synapse.FromNeuronAxon))
+		e.uvarint(uint64(synapse.ToNeuronDendrite))
+		e.uvarint(uint64(synapse.ActivationHistory))
+		e.byte(synapse.Delay)
+	}
+	e.checksum()
+
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// nurReader is a bufio.Reader for files, or a bytes.Reader for mapped memory.
+type nurReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func newNurDecoder(r nurReader) *nurDecoder {
+	return &nurDecoder{r: r, crc: crc32.NewIEEE()}
+}
+
+/*
+nurDecoder reads from a buffered reader and keeps a running checksum of the
+section being read. The first error sticks, and later reads return zeros.
+*/
+type nurDecoder struct {
+	r   nurReader
+	crc hash.Hash32
+	buf [8]byte
+	err error
+}
+
+// ReadByte lets binary.ReadUvarint read through the checksum.
+func (d *nurDecoder) ReadByte() (byte, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	var b byte
+	b, d.err = d.r.ReadByte()
+	if d.err == io.EOF {
+		d.err = io.ErrUnexpectedEOF
+	}
+	d.buf[0] = b
+	d.crc.Write(d.buf[:1])
+	return b, d.err
+}
+
+func (d *nurDecoder) read(p []byte) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = io.ReadFull(d.r, p)
+	if d.err == io.EOF {
+		d.err = io.ErrUnexpectedEOF
+	}
+	d.crc.Write(p)
+}
+
+func (d *nurDecoder) byte() byte {
+	b, _ := d.ReadByte()
+	return b
+}
+
+func (d *nurDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var x uint64
+	x, d.err = binary.ReadUvarint(d)
+	return x
+}
+
+func (d *nurDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	var x int64
+	x, d.err = binary.ReadVarint(d)
+	return x
+}
+
+func (d *nurDecoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > maxNurString {
+		d.err = fmt.Errorf("Field of %d bytes is too long", n)
+		return nil
+	}
+	p := make([]byte, n)
+	d.read(p)
+	return p
+}
+
+func (d *nurDecoder) synapseIDs() map[SynapseID]bool {
+	n := d.uvarint()
+	synapses := make(map[SynapseID]bool)
+	id := SynapseID(0)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		id += SynapseID(d.uvarint())
+		synapses[id] = true
+	}
+	return synapses
+}
+
+// checksum makes sure the section that was just read is what was written.
+func (d *nurDecoder) checksum(section string) {
+	want := d.crc.Sum32()
+	d.read(d.buf[:4])
+	if d.err == nil && binary.LittleEndian.Uint32(d.buf[:4]) != want {
+		d.err = fmt.Errorf("Checksum of the %s does not match, the file is corrupt", section)
+	}
+	d.crc.Reset()
+}
+
+/*
+readBinary reads a binary .nur file, version 2 or 3, onto a new network,
+replacing its laws, cells and synapses. It does not check the network - see
+`afterLoad`.
+*/
+func (network *Network) readBinary(r nurReader) error {
+	d := newNurDecoder(r)
+	cellSlots, synapseSlots, err := network.readBinaryHeader(d)
+	if err != nil {
+		return err
+	}
+
+	network.Cells = make([]*Cell, 0, capSlots(cellSlots))
+	for i := uint64(0); i < cellSlots && d.err == nil; i++ {
+		network.Cells = append(network.Cells, network.readBinaryCell(d, CellID(i)))
+	}
+	d.checksum("cells")
+
+	network.Synapses = make([]*Synapse, 0, capSlots(synapseSlots))
+	for i := uint64(0); i < synapseSlots && d.err == nil; i++ {
+		network.Synapses = append(network.Synapses, network.readBinarySynapse(d, SynapseID(i)))
+	}
+	d.checksum("synapses")
+
+	return d.err
+}
+
+/*
+readBinaryHeader reads everything before the cells onto the network, and
+returns how many cell and synapse slots follow.
+*/
+func (network *Network) readBinaryHeader(d *nurDecoder) (cellSlots, synapseSlots uint64, err error) {
+	magic := make([]byte, len(nurMagic))
+	d.read(magic)
+	if d.err == nil && string(magic) != nurMagic {
+		return 0, 0, errors.New("Not a binary network file")
+	}
+	d.read(d.buf[:2])
+	if d.err != nil {
+		return 0, 0, d.err
+	}
+	version := binary.LittleEndian.Uint16(d.buf[:2])
+	if version != NurFormatVersion && version != nurFormatVersionUnversioned {
+		return 0, 0, fmt.Errorf("Unsupported binary network file version %d", version)
+	}
+	network.SchemaVersion = 0
+	if version > nurFormatVersionUnversioned {
+		network.SchemaVersion = int(d.uvarint())
+	}
+	lawsJSON := d.bytes()
+	regionsJSON := d.bytes()
+	d.read(d.buf[:8])
+	network.Seed = int64(binary.LittleEndian.Uint64(d.buf[:8]))
+	network.Disabled = d.byte() == 1
+	network.CellIDCursor = int(d.uvarint())
+	network.SynIDCursor = int(d.uvarint())
+	cellSlots = d.uvarint()
+	synapseSlots = d.uvarint()
+	d.checksum("header")
+	if d.err != nil {
+		return 0, 0, d.err
+	}
+	if err = json.Unmarshal(lawsJSON, network.Laws); err != nil {
+		return 0, 0, err
+	}
+	if err = json.Unmarshal(regionsJSON, &network.Regions); err != nil {
+		return 0, 0, err
+	}
+	return cellSlots, synapseSlots, nil
+}
+
+// readBinaryCell reads the cell in the next slot, which is nil when pruned.
+func (network *Network) readBinaryCell(d *nurDecoder, id CellID) *Cell {
+	if d.byte() == 0 {
+		return nil
+	}
+	flags := d.byte()
+	cell := &Cell{
+		ID:       id,
+		Network:  network,
+		Immortal: flags&nurCellImmortal != 0,
+		WasFired: flags&nurCellWasFired != 0,
+		OnFired:  make([]func(CellID), 0),
+	}
+	cell.Type = CellType(d.byte())
+	cell.Voltage = int16(d.varint())
+	cell.Threshold = int(d.varint())
+	cell.RefractorySteps = d.byte()
+	cell.Tag = string(d.bytes())
+	cell.Region = string(d.bytes())
+	cell.AxonSynapses = d.synapseIDs()
+	cell.DendriteSynapses = d.synapseIDs()
+	return cell
+}
+
+// readBinarySynapse reads the synapse in the next slot, which is nil when pruned.
+func (network *Network) readBinarySynapse(d *nurDecoder, id SynapseID) *Synapse {
+	if d.byte() == 0 {
+		return nil
+	}
+	synapse := &Synapse{ID: id, Network: network}
+	synapse.Millivolts = int16(d.varint())
+	synapse.FromNeuronAxon = CellID(d.uvarint())
+	synapse.ToNeuronDendrite = CellID(d.uvarint())
+	synapse.ActivationHistory = uint(d.uvarint())
+	synapse.Delay = d.byte()
+	return synapse
+}
+
+// capSlots is how many slots to allocate up front, without trusting a corrupt
+// count too much.
+func capSlots(slots uint64) int {
+	if slots > 1<<20 {
+		return 1 << 20
+	}
+	return int(slots)
+}
+
+/*
+ReadNetworkBinary reads a network written by WriteBinary, and checks it the
+same way LoadNetworkFromFile does.
+*/
+func ReadNetworkBinary(r io.Reader) (*Network, error) {
+	network := NewNetwork()
+	if err := network.readBinary(bufio.NewReader(r)); err != nil {
+		return network, err
+	}
+	return network, network.afterLoad("binary network", true)
+}
+
+/*
+SaveToFileBinary writes the network to a file in the binary .nur format. See
+WriteBinary. LoadNetworkFromFile reads it, and the gzipped JSON from
+SaveToFile, telling them apart by the first bytes.
+*/
+func (network *Network) SaveToFileBinary(filepath string) (err error) {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return network.WriteBinary(file)
+}
@@ -0,0 +1,105 @@
+package potential
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NurFile(t *testing.T) {
+	var network *Network
+	before := func() {
+		network = NewNetwork()
+		network.SetSeed(42)
+		network.Grow(30, 5, 10)
+		network.GetCell(3).Tag = "three"
+		network.SetCellRegion(4, "sensory")
+		network.AddRegion("sensory").Connect("motor", 2)
+		network.GetCell(5).Immortal = true
+		network.GetSyn(2).Delay = 3
+		network.GetSyn(2).ActivationHistory = 300
+		network.PruneSynapse(1)
+	}
+
+	t.Run("writes and reads back the same network", func(t *testing.T) {
+		before()
+		var buf bytes.Buffer
+		assert.NoError(t, network.WriteBinary(&buf))
+		net2, err := ReadNetworkBinary(&buf)
+		assert.NoError(t, err)
+		n1, err := network.ToJSON()
+		assert.NoError(t, err)
+		n2, err := net2.ToJSON()
+		assert.NoError(t, err)
+		assert.Equal(t, string(n1), string(n2))
+		assert.Nil(t, net2.Synapses[1])
+		assert.Equal(t, []CellID{4}, net2.CellsInRegion("sensory"))
+	})
+	t.Run("is smaller than gzipped JSON", func(t *testing.T) {
+		before()
+		assert.NoError(t, network.SaveToFile("_network_gz.test.nur"))
+		assert.NoError(t, network.SaveToFileBinary("_network_v2.test.nur"))
+		gz, err := os.Stat("_network_gz.test.nur")
+		assert.NoError(t, err)
+		v2, err := os.Stat("_network_v2.test.nur")
+		assert.NoError(t, err)
+		assert.True(t, v2.Size() < gz.Size(), "binary", v2.Size(), "gzipped JSON", gz.Size())
+	})
+	t.Run("LoadNetworkFromFile reads both formats", func(t *testing.T) {
+		before()
+		assert.NoError(t, network.SaveToFile("_network_gz.test.nur"))
+		assert.NoError(t, network.SaveToFileBinary("_network_v2.test.nur"))
+		assert.NoError(t, network.SaveToFileReadable("_network_plain.test.json"))
+		n1, _ := network.ToJSON()
+		for _, file := range []string{"_network_gz.test.nur", "_network_v2.test.nur", "_network_plain.test.json"} {
+			net2, err := LoadNetworkFromFile(file)
+			assert.NoError(t, err, file)
+			n2, _ := net2.ToJSON()
+			assert.Equal(t, string(n1), string(n2), file)
+		}
+	})
+	t.Run("catches corrupt files by their checksums", func(t *testing.T) {
+		before()
+		var buf bytes.Buffer
+		assert.NoError(t, network.WriteBinary(&buf))
+		contents := buf.Bytes()
+		contents[len(contents)-10] ^= 0xff
+		assert.NoError(t, ioutil.WriteFile("_network_corrupt.test.nur", contents, os.ModePerm))
+		_, err := LoadNetworkFromFile("_network_corrupt.test.nur")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "corrupt")
+	})
+	t.Run("catches files that were cut short", func(t *testing.T) {
+		before()
+		var buf bytes.Buffer
+		assert.NoError(t, network.WriteBinary(&buf))
+		_, err := ReadNetworkBinary(bytes.NewReader(buf.Bytes()[:buf.Len()/2]))
+		assert.Error(t, err)
+	})
+	t.Run("reads version 2 files, from before schema versions", func(t *testing.T) {
+		before()
+		file, err := os.Create("_network_format2.test.nur")
+		assert.NoError(t, err)
+		assert.NoError(t, network.writeBinary(file, 2))
+		assert.NoError(t, file.Close())
+
+		loaded, report, err := loadNetworkFromFile("_network_format2.test.nur", true)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, report.From)
+		assert.Equal(t, CurrentSchemaVersion, loaded.SchemaVersion)
+		assert.Equal(t, len(network.Cells), len(loaded.Cells))
+		assert.Equal(t, len(network.Synapses), len(loaded.Synapses))
+	})
+	t.Run("refuses versions it does not know", func(t *testing.T) {
+		before()
+		var buf bytes.Buffer
+		assert.NoError(t, network.WriteBinary(&buf))
+		contents := buf.Bytes()
+		contents[len(nurMagic)] = 9
+		_, err := ReadNetworkBinary(bytes.NewReader(contents))
+		assert.EqualError(t, err, "Unsupported binary network file version 9")
+	})
+}
@@ -0,0 +1,71 @@
+package potential
+
+/*
+StepObserver is told what happens in a network as it steps and learns, for
+building recorders, metrics and visualizers. Register one with
+`Network.AddObserver`.
+
+Embed BaseStepObserver to only implement the methods you need. Observers are
+called on the goroutine that changes the network, so they should be quick, and
+must not change the network themselves. `network.Steps()` is the current step.
+Observers are not saved or cloned with the network.
+*/
+type StepObserver interface {
+	// CellsFired is called at the end of every Step with the cells that
+	// fired, in order. It may be empty.
+	CellsFired(network *Network, cells []CellID)
+	// SynapsesDelivered is called during every Step with the synapses that
+	// applied their voltage, grouped by the cell they delivered to. It may be
+	// empty.
+	SynapsesDelivered(network *Network, synapses []SynapseID)
+	// SynapseReinforced is called when a synapse is moved away from zero.
+	SynapseReinforced(network *Network, synapseID SynapseID)
+	// SynapseCreated is called when a synapse links two cells.
+	SynapseCreated(network *Network, synapseID SynapseID)
+	// CellCreated is called when a cell is added to the network.
+	CellCreated(network *Network, cellID CellID)
+	// CellPruned is called when a cell is removed from the network.
+	CellPruned(network *Network, cellID CellID)
+}
+
+/*
+BaseStepObserver does nothing for every StepObserver method.
+*/
+type BaseStepObserver struct{}
+
+// CellsFired is documented on StepObserver.
+func (BaseStepObserver) CellsFired(network *Network, cells []CellID) {}
+
+// SynapsesDelivered is documented on StepObserver.
+func (BaseStepObserver) SynapsesDelivered(network *Network, synapses []SynapseID) {}
+
+// SynapseReinforced is documented on StepObserver.
+func (BaseStepObserver) SynapseReinforced(network *Network, synapseID SynapseID) {}
+
+// SynapseCreated is documented on StepObserver.
+func (BaseStepObserver) SynapseCreated(network *Network, synapseID SynapseID) {}
+
+// CellCreated is documented on StepObserver.
+func (BaseStepObserver) CellCreated(network *Network, cellID CellID) {}
+
+// CellPruned is documented on StepObserver.
+func (BaseStepObserver) CellPruned(network *Network, cellID CellID) {}
+
+/*
+AddObserver registers an observer on the network.
+*/
+func (network *Network) AddObserver(observer StepObserver) {
+	network.observers = append(network.observers, observer)
+}
+
+/*
+RemoveObserver unregisters an observer from the network.
+*/
+func (network *Network) RemoveObserver(observer StepObserver) {
+	for i, o := range network.observers {
+		if o == observer {
+			network.observers = append(network.observers[:i], network.observers[i+1:]...)
+			return
+		}
+	}
+}
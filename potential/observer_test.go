@@ -0,0 +1,78 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingObserver records what it was told, and ignores cell creation
+type countingObserver struct {
+	BaseStepObserver
+	fired      [][]CellID
+	delivered  [][]SynapseID
+	reinforced []SynapseID
+	created    []SynapseID
+	pruned     []CellID
+}
+
+func (o *countingObserver) CellsFired(network *Network, cells []CellID) {
+	o.fired = append(o.fired, cells)
+}
+func (o *countingObserver) SynapsesDelivered(network *Network, synapses []SynapseID) {
+	o.delivered = append(o.delivered, synapses)
+}
+func (o *countingObserver) SynapseReinforced(network *Network, synapseID SynapseID) {
+	o.reinforced = append(o.reinforced, synapseID)
+}
+func (o *countingObserver) SynapseCreated(network *Network, synapseID SynapseID) {
+	o.created = append(o.created, synapseID)
+}
+func (o *countingObserver) CellPruned(network *Network, cellID CellID) {
+	o.pruned = append(o.pruned, cellID)
+}
+
+func Test_StepObserver(t *testing.T) {
+	t.Run("is told what each step delivered and fired", func(t *testing.T) {
+		network := NewNetwork()
+		observer := &countingObserver{}
+		network.AddObserver(observer)
+		a := NewCell(network)
+		b := NewCell(network)
+		c := NewCell(network)
+		ab := network.linkCells(a.ID, b.ID)
+		ab.Millivolts = 2000
+		ac := network.linkCells(a.ID, c.ID)
+		ac.Millivolts = 10
+		assert.Equal(t, []SynapseID{ab.ID, ac.ID}, observer.created)
+
+		a.FireActionPotential()
+		network.Step()
+		network.Step()
+
+		assert.Equal(t, [][]SynapseID{{ab.ID, ac.ID}, nil}, observer.delivered)
+		assert.Equal(t, [][]CellID{{b.ID}, {}}, observer.fired)
+		// the hebbian rule reinforced the synapse that made b fire
+		assert.Equal(t, []SynapseID{ab.ID}, observer.reinforced)
+	})
+	t.Run("is told about pruned cells", func(t *testing.T) {
+		network := NewNetwork()
+		observer := &countingObserver{}
+		network.AddObserver(observer)
+		a := NewCell(network)
+		b := NewCell(network)
+		ab := network.linkCells(a.ID, b.ID)
+		network.PruneSynapse(ab.ID)
+		assert.Equal(t, []CellID{a.ID, b.ID}, observer.pruned)
+	})
+	t.Run("can be removed", func(t *testing.T) {
+		network := NewNetwork()
+		observer := &countingObserver{}
+		network.AddObserver(observer)
+		network.RemoveObserver(observer)
+		a := NewCell(network)
+		b := NewCell(network)
+		network.linkCells(a.ID, b.ID)
+		assert.Empty(t, observer.created)
+	})
+}
@@ -0,0 +1,267 @@
+package potential
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+/*
+PlasticityRule is a learning rule that changes synapses as the network fires.
+
+The network's `PlasticityRule` law picks the rule by name, so the rule is saved
+with the network. Each network gets its own instance of the rule, so a rule may
+keep state about the network, like spike times.
+*/
+type PlasticityRule interface {
+	// Name is what the rule is registered as, and saved in the laws as.
+	Name() string
+	/*
+		Spiked is called every time a cell fires, including cells fired from
+		outside of Step. `network.Steps()` is the time of the spike.
+	*/
+	Spiked(network *Network, cellID CellID)
+	/*
+		Tallied is called during Step for each cell that received voltage, with
+		the synapses that delivered it, and whether that made the cell fire.
+	*/
+	Tallied(network *Network, cellID CellID, synapses []SynapseID, fired bool)
+	/*
+		Reset forgets any state the rule keeps, such as when the network is
+		reset for training or its IDs change.
+	*/
+	Reset()
+}
+
+var plasticityRules = map[string]func() PlasticityRule{
+	"hebbian": func() PlasticityRule { return &HebbianRule{} },
+	"stdp":    func() PlasticityRule { return NewSTDPRule() },
+	"none":    func() PlasticityRule { return &NoPlasticityRule{} },
+}
+
+/*
+RegisterPlasticityRule makes a custom rule available to networks by name. It
+should be called before any networks use it, such as from `init()`.
+*/
+func RegisterPlasticityRule(name string, factory func() PlasticityRule) {
+	plasticityRules[name] = factory
+}
+
+/*
+NewPlasticityRule creates a new instance of the rule registered under name.
+*/
+func NewPlasticityRule(name string) (PlasticityRule, error) {
+	factory, ok := plasticityRules[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown plasticity rule %s", name)
+	}
+	return factory(), nil
+}
+
+/*
+SetPlasticityRule changes the learning rule of the network, and records it in
+the laws so it is saved with the network. The rule should be registered under
+its name, or the network cannot be loaded again.
+*/
+func (network *Network) SetPlasticityRule(rule PlasticityRule) {
+	network.Laws.PlasticityRule = rule.Name()
+	network.plasticity = rule
+}
+
+/*
+plasticityRule returns the instance of the rule in the laws, creating it if the
+laws changed.
+*/
+func (network *Network) plasticityRule() PlasticityRule {
+	if network.plasticity != nil && network.plasticity.Name() == network.Laws.PlasticityRule {
+		return network.plasticity
+	}
+	rule, err := NewPlasticityRule(network.Laws.PlasticityRule)
+	if err != nil {
+		panic(err)
+	}
+	network.plasticity = rule
+	return rule
+}
+
+/*
+Steps is how many times the network has stepped. It is the clock that spike
+times are measured with.
+*/
+func (network *Network) Steps() int {
+	return network.steps
+}
+
+/*
+HebbianRule is the default plasticity rule. Synapses that made a cell fire are
+reinforced, and synapses that delivered voltage to a cell that did not fire
+are weakened by the `SynapseWeakenRatio` law.
+*/
+type HebbianRule struct{}
+
+/*
+Name is documented on PlasticityRule.
+*/
+func (rule *HebbianRule) Name() string {
+	return "hebbian"
+}
+
+/*
+Spiked is documented on PlasticityRule. Spike times do not matter to this rule.
+*/
+func (rule *HebbianRule) Spiked(network *Network, cellID CellID) {}
+
+/*
+Tallied is documented on PlasticityRule.
+*/
+func (rule *HebbianRule) Tallied(network *Network, cellID CellID, synapses []SynapseID, fired bool) {
+	for _, synapseID := range synapses {
+		if fired {
+			// Reward the synapses that were involved in this cell firing.
+			network.GetSyn(synapseID).reinforce()
+			continue
+		}
+		// This may prune the synapse, and the cell too, if it has nothing left.
+		network.GetSyn(synapseID).weaken()
+	}
+}
+
+/*
+Reset is documented on PlasticityRule.
+*/
+func (rule *HebbianRule) Reset() {}
+
+/*
+STDPRule is spike-timing-dependent plasticity. It remembers when each cell last
+spiked.
+
+When a cell spikes, the synapses from cells that spiked shortly before it
+are potentiated (moved away from zero), because they may have caused it.
+The synapses onto cells that spiked shortly before it are depressed (moved
+toward zero), because it fired too late to have helped them.
+
+The change falls off exponentially as the spikes get further apart, by the
+`STDPTimeConstant` law, and spikes more than `STDPWindow` steps apart do not
+count. Spikes in the same step do not count either, because a synapse always
+takes at least one step to deliver.
+*/
+type STDPRule struct {
+	lastSpike map[CellID]int
+}
+
+/*
+NewSTDPRule is a factory for STDPRule.
+*/
+func NewSTDPRule() *STDPRule {
+	return &STDPRule{lastSpike: make(map[CellID]int)}
+}
+
+/*
+Name is documented on PlasticityRule.
+*/
+func (rule *STDPRule) Name() string {
+	return "stdp"
+}
+
+/*
+Spiked is documented on PlasticityRule.
+*/
+func (rule *STDPRule) Spiked(network *Network, cellID CellID) {
+	now := network.Steps()
+	rule.lastSpike[cellID] = now
+	cell := network.GetCell(cellID)
+	l := network.Laws
+
+	// pre before post - potentiate
+	for _, synapseID := range sortedSynapseIDs(cell.DendriteSynapses) {
+		synapse := network.GetSyn(synapseID)
+		amount := rule.change(now, synapse.FromNeuronAxon, l.STDPPotentiation, network)
+		if amount > 0 {
+			reinforceByAmount(synapse, amount)
+		}
+	}
+	// post before pre - depress
+	for _, synapseID := range sortedSynapseIDs(cell.AxonSynapses) {
+		if !network.SynExists(synapseID) { // pruned along the way
+			continue
+		}
+		synapse := network.GetSyn(synapseID)
+		amount := rule.change(now, synapse.ToNeuronDendrite, l.STDPDepression, network)
+		if amount > 0 {
+			weakenByAmount(synapse, amount)
+		}
+	}
+}
+
+// change is how much a synapse should change, given when the cell on the other
+// side of it last spiked.
+func (rule *STDPRule) change(now int, otherCellID CellID, max int16, network *Network) int16 {
+	spikedAt, ok := rule.lastSpike[otherCellID]
+	if !ok {
+		return 0
+	}
+	dt := now - spikedAt
+	if dt < 1 || dt > int(network.Laws.STDPWindow) {
+		return 0
+	}
+	return int16(math.Round(float64(max) * math.Exp(-float64(dt-1)/network.Laws.STDPTimeConstant)))
+}
+
+/*
+Tallied is documented on PlasticityRule. Only spike times matter to this rule.
+*/
+func (rule *STDPRule) Tallied(network *Network, cellID CellID, synapses []SynapseID, fired bool) {}
+
+/*
+Reset is documented on PlasticityRule.
+*/
+func (rule *STDPRule) Reset() {
+	rule.lastSpike = make(map[CellID]int)
+}
+
+/*
+MarshalState is documented on StatefulPlasticityRule. It saves the spike times.
+*/
+func (rule *STDPRule) MarshalState() ([]byte, error) {
+	return json.Marshal(rule.lastSpike)
+}
+
+/*
+UnmarshalState is documented on StatefulPlasticityRule.
+*/
+func (rule *STDPRule) UnmarshalState(data []byte) error {
+	lastSpike := make(map[CellID]int)
+	if err := json.Unmarshal(data, &lastSpike); err != nil {
+		return err
+	}
+	rule.lastSpike = lastSpike
+	return nil
+}
+
+/*
+NoPlasticityRule never changes synapses, freezing the network while it fires.
+*/
+type NoPlasticityRule struct{}
+
+/*
+Name is documented on PlasticityRule.
+*/
+func (rule *NoPlasticityRule) Name() string {
+	return "none"
+}
+
+/*
+Spiked is documented on PlasticityRule.
+*/
+func (rule *NoPlasticityRule) Spiked(network *Network, cellID CellID) {}
+
+/*
+Tallied is documented on PlasticityRule.
+*/
+func (rule *NoPlasticityRule) Tallied(network *Network, cellID CellID, synapses []SynapseID, fired bool) {
+}
+
+/*
+Reset is documented on PlasticityRule.
+*/
+func (rule *NoPlasticityRule) Reset() {}
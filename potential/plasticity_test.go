@@ -0,0 +1,160 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingRule remembers what it was called with
+type recordingRule struct {
+	spiked  []CellID
+	fired   map[CellID][]SynapseID
+	unfired map[CellID][]SynapseID
+}
+
+func (rule *recordingRule) Name() string { return "recording" }
+func (rule *recordingRule) Spiked(network *Network, cellID CellID) {
+	rule.spiked = append(rule.spiked, cellID)
+}
+func (rule *recordingRule) Tallied(network *Network, cellID CellID, synapses []SynapseID, fired bool) {
+	if fired {
+		rule.fired[cellID] = synapses
+	} else {
+		rule.unfired[cellID] = synapses
+	}
+}
+func (rule *recordingRule) Reset() {}
+
+func Test_PlasticityRule(t *testing.T) {
+	t.Run("networks use the hebbian rule by default", func(t *testing.T) {
+		network := NewNetwork()
+		assert.Equal(t, "hebbian", network.Laws.PlasticityRule)
+		assert.IsType(t, &HebbianRule{}, network.plasticityRule())
+	})
+	t.Run("Step calls the rule with the synapses onto each cell", func(t *testing.T) {
+		network := NewNetwork()
+		rule := &recordingRule{fired: make(map[CellID][]SynapseID), unfired: make(map[CellID][]SynapseID)}
+		network.SetPlasticityRule(rule)
+		a := NewCell(network)
+		b := NewCell(network)
+		c := NewCell(network)
+		ab := network.linkCells(a.ID, b.ID)
+		ab.Millivolts = 2000
+		ac := network.linkCells(a.ID, c.ID)
+		ac.Millivolts = 10
+
+		a.FireActionPotential()
+		network.Step()
+
+		assert.Equal(t, []CellID{a.ID, b.ID}, rule.spiked)
+		assert.Equal(t, map[CellID][]SynapseID{b.ID: {ab.ID}}, rule.fired)
+		assert.Equal(t, map[CellID][]SynapseID{c.ID: {ac.ID}}, rule.unfired)
+	})
+	t.Run("the none rule leaves the synapses alone", func(t *testing.T) {
+		network := NewNetwork()
+		network.Laws.PlasticityRule = "none"
+		a := NewCell(network)
+		b := NewCell(network)
+		c := NewCell(network)
+		ab := network.linkCells(a.ID, b.ID)
+		ab.Millivolts = 2000
+		ac := network.linkCells(a.ID, c.ID)
+		ac.Millivolts = 10
+
+		a.FireActionPotential()
+		network.Step()
+
+		assert.Equal(t, []CellID{b.ID}, network.FiredLastStep())
+		assert.Equal(t, int16(2000), ab.Millivolts)
+		assert.Equal(t, int16(10), ac.Millivolts)
+	})
+	t.Run("the rule is saved with the network", func(t *testing.T) {
+		network := NewNetwork()
+		network.SetPlasticityRule(NewSTDPRule())
+		err := network.SaveToFile("_network_stdp.test.json")
+		assert.NoError(t, err)
+		net2, err := LoadNetworkFromFile("_network_stdp.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, "stdp", net2.Laws.PlasticityRule)
+		assert.IsType(t, &STDPRule{}, net2.plasticityRule())
+	})
+	t.Run("loading a network with an unknown rule returns an error", func(t *testing.T) {
+		network := NewNetwork()
+		network.Laws.PlasticityRule = "telepathy"
+		err := network.SaveToFile("_network_badrule.test.json")
+		assert.NoError(t, err)
+		_, err = LoadNetworkFromFile("_network_badrule.test.json")
+		assert.Error(t, err)
+	})
+	t.Run("custom rules can be registered", func(t *testing.T) {
+		RegisterPlasticityRule("recording", func() PlasticityRule {
+			return &recordingRule{fired: make(map[CellID][]SynapseID), unfired: make(map[CellID][]SynapseID)}
+		})
+		rule, err := NewPlasticityRule("recording")
+		assert.NoError(t, err)
+		assert.Equal(t, "recording", rule.Name())
+	})
+}
+
+func Test_STDPRule(t *testing.T) {
+	var network *Network
+	var pre, post *Cell
+	var synapse *Synapse
+	before := func() {
+		network = NewNetwork()
+		network.SetPlasticityRule(NewSTDPRule())
+		pre = NewCell(network)
+		post = NewCell(network)
+		synapse = network.linkCells(pre.ID, post.ID)
+		synapse.Millivolts = 100 // not enough to fire post on its own
+	}
+
+	t.Run("pre then post potentiates the synapse", func(t *testing.T) {
+		before()
+		pre.FireActionPotential()
+		network.Step()
+		post.FireActionPotential()
+		assert.Equal(t, 100+network.Laws.STDPPotentiation, synapse.Millivolts)
+	})
+	t.Run("post then pre depresses the synapse", func(t *testing.T) {
+		before()
+		post.FireActionPotential()
+		network.Step()
+		pre.FireActionPotential()
+		assert.Equal(t, 100-network.Laws.STDPDepression, synapse.Millivolts)
+	})
+	t.Run("the change falls off as the spikes get further apart", func(t *testing.T) {
+		before()
+		pre.FireActionPotential()
+		for i := 0; i < 6; i++ {
+			network.Step()
+		}
+		post.FireActionPotential()
+		assert.True(t, synapse.Millivolts > 100)
+		assert.True(t, synapse.Millivolts < 100+network.Laws.STDPPotentiation)
+	})
+	t.Run("spikes further apart than the window do nothing", func(t *testing.T) {
+		before()
+		pre.FireActionPotential()
+		for i := 0; i <= int(network.Laws.STDPWindow); i++ {
+			network.Step()
+		}
+		post.FireActionPotential()
+		assert.Equal(t, int16(100), synapse.Millivolts)
+	})
+	t.Run("spikes in the same step do nothing", func(t *testing.T) {
+		before()
+		pre.FireActionPotential()
+		post.FireActionPotential()
+		assert.Equal(t, int16(100), synapse.Millivolts)
+	})
+	t.Run("reset forgets the spikes", func(t *testing.T) {
+		before()
+		pre.FireActionPotential()
+		network.Step()
+		network.ResetForTraining()
+		post.FireActionPotential()
+		assert.Equal(t, int16(100), synapse.Millivolts)
+	})
+}
@@ -0,0 +1,76 @@
+package potential
+
+import "log"
+
+/*
+PruneReport lists what a prune removed, or would remove.
+*/
+type PruneReport struct {
+	Synapses []SynapseID
+	Cells    []CellID
+}
+
+/*
+Print logs the totals of the report.
+*/
+func (report PruneReport) Print() {
+	log.Println("Prune")
+	log.Println(" ", len(report.Synapses), "synapses")
+	log.Println(" ", len(report.Cells), "cells")
+}
+
+/*
+FindInactive reports the synapses that delivered voltage fewer than minActivations
+times, and the cells that would be left without any synapses if they were pruned.
+Immortal cells are never in the report.
+
+It does not change the network, so it works as a dry run of PruneInactive.
+*/
+func (network *Network) FindInactive(minActivations uint) (report PruneReport) {
+	silent := make(map[SynapseID]bool)
+	for _, synapse := range network.Synapses {
+		if synapse == nil { // pruned
+			continue
+		}
+		if synapse.ActivationHistory < minActivations {
+			silent[synapse.ID] = true
+			report.Synapses = append(report.Synapses, synapse.ID)
+		}
+	}
+
+	allSilent := func(synapses map[SynapseID]bool) bool {
+		for synapseID := range synapses {
+			if !silent[synapseID] {
+				return false
+			}
+		}
+		return true
+	}
+	for _, cell := range network.Cells {
+		if cell == nil || cell.Immortal {
+			continue
+		}
+		// cells that had no synapses to begin with are not this prune's doing
+		hasSynapses := len(cell.AxonSynapses) > 0 || len(cell.DendriteSynapses) > 0
+		if hasSynapses && allSilent(cell.AxonSynapses) && allSilent(cell.DendriteSynapses) {
+			report.Cells = append(report.Cells, cell.ID)
+		}
+	}
+	return report
+}
+
+/*
+PruneInactive removes the synapses that delivered voltage fewer than minActivations
+times, as counted in their ActivationHistory. Cells left without any synapses are
+pruned too, unless they are Immortal.
+
+It should only run on the main network while no clones are training, because
+the diff from a clone would bring the pruned synapses back.
+*/
+func (network *Network) PruneInactive(minActivations uint) (report PruneReport) {
+	report = network.FindInactive(minActivations)
+	for _, synapseID := range report.Synapses {
+		network.PruneSynapse(synapseID)
+	}
+	return report
+}
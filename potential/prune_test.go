@@ -0,0 +1,96 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ActivationHistory(t *testing.T) {
+	t.Run("Step counts each delivery of a synapse", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		s := network.linkCells(a.ID, b.ID)
+		s.Millivolts = 10
+
+		a.FireActionPotential()
+		network.Step()
+		a.FireActionPotential()
+		network.Step()
+		network.Step()
+		assert.Equal(t, uint(2), s.ActivationHistory)
+	})
+	t.Run("is saved with the network", func(t *testing.T) {
+		network := NewNetwork()
+		s := network.linkCells(NewCell(network).ID, NewCell(network).ID)
+		s.ActivationHistory = 9
+		err := network.SaveToFile("_network_history.test.json")
+		assert.NoError(t, err)
+		net2, err := LoadNetworkFromFile("_network_history.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, uint(9), net2.GetSyn(s.ID).ActivationHistory)
+	})
+	t.Run("starts over on a clone, so diffs only add the new activations", func(t *testing.T) {
+		network := NewNetwork()
+		s := network.linkCells(NewCell(network).ID, NewCell(network).ID)
+		s.ActivationHistory = 9
+		cloned := CloneNetwork(network)
+		assert.Equal(t, uint(0), cloned.GetSyn(s.ID).ActivationHistory)
+		cloned.GetSyn(s.ID).ActivationHistory = 2
+		ApplyDiff(DiffNetworks(network, cloned), network)
+		assert.Equal(t, uint(11), s.ActivationHistory)
+	})
+}
+
+func Test_PruneInactive(t *testing.T) {
+	// a -> b -> c, and b -> d
+	var network *Network
+	var a, b, c, d *Cell
+	var ab, bc, bd *Synapse
+	before := func() {
+		network = NewNetwork()
+		a = NewCell(network)
+		b = NewCell(network)
+		c = NewCell(network)
+		d = NewCell(network)
+		ab = network.linkCells(a.ID, b.ID)
+		bc = network.linkCells(b.ID, c.ID)
+		bd = network.linkCells(b.ID, d.ID)
+		ab.ActivationHistory = 5
+		bc.ActivationHistory = 1
+		bd.ActivationHistory = 0
+	}
+
+	t.Run("FindInactive reports without changing the network", func(t *testing.T) {
+		before()
+		report := network.FindInactive(2)
+		assert.Equal(t, []SynapseID{bc.ID, bd.ID}, report.Synapses)
+		assert.Equal(t, []CellID{c.ID, d.ID}, report.Cells)
+		assert.True(t, network.SynExists(bc.ID))
+		assert.True(t, network.CellExists(c.ID))
+	})
+	t.Run("removes silent synapses and the cells they orphan", func(t *testing.T) {
+		before()
+		report := network.PruneInactive(2)
+		assert.Equal(t, []SynapseID{bc.ID, bd.ID}, report.Synapses)
+		assert.Equal(t, []CellID{c.ID, d.ID}, report.Cells)
+		assert.True(t, network.SynExists(ab.ID))
+		assert.False(t, network.SynExists(bc.ID))
+		assert.False(t, network.SynExists(bd.ID))
+		assert.True(t, network.CellExists(b.ID))
+		assert.False(t, network.CellExists(c.ID))
+		assert.False(t, network.CellExists(d.ID))
+		ok, report2 := CheckIntegrity(network)
+		assert.True(t, ok, report2)
+	})
+	t.Run("keeps immortal cells", func(t *testing.T) {
+		before()
+		d.Immortal = true
+		report := network.PruneInactive(1)
+		assert.Equal(t, []SynapseID{bd.ID}, report.Synapses)
+		assert.Empty(t, report.Cells)
+		assert.True(t, network.CellExists(d.ID))
+		assert.Equal(t, 0, len(d.DendriteSynapses))
+	})
+}
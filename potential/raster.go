@@ -0,0 +1,174 @@
+package potential
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+/*
+SpikeEvent is one cell firing on one step.
+*/
+type SpikeEvent struct {
+	Step int    `json:"step"`
+	Cell CellID `json:"cell"`
+}
+
+/*
+SpikeRaster records every cell that fires, and when, so the timing of a run is
+not lost like it is in a FiringPattern.
+
+Start recording with `network.AddObserver(raster)`, then fire the network as
+usual with FireNetworkUntilDone or Sample. Steps are `Network.Steps()`, so they
+keep counting up across runs. Seed cells are recorded on the steps they spread,
+not when they are fired by hand.
+*/
+type SpikeRaster struct {
+	BaseStepObserver
+	Events []SpikeEvent
+}
+
+/*
+NewSpikeRaster is a SpikeRaster factory.
+*/
+func NewSpikeRaster() *SpikeRaster {
+	return &SpikeRaster{Events: make([]SpikeEvent, 0)}
+}
+
+/*
+CellsFired records the cells that fired this step.
+*/
+func (raster *SpikeRaster) CellsFired(network *Network, cells []CellID) {
+	step := network.Steps()
+	for _, cellID := range cells {
+		raster.Events = append(raster.Events, SpikeEvent{Step: step, Cell: cellID})
+	}
+}
+
+/*
+WriteCSV writes the raster as `step,cell` rows, with a header.
+*/
+func (raster *SpikeRaster) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"step", "cell"}); err != nil {
+		return err
+	}
+	for _, event := range raster.Events {
+		row := []string{strconv.Itoa(event.Step), strconv.FormatUint(uint64(event.Cell), 10)}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+/*
+WriteJSON writes the raster as a JSON array of `{"step":1,"cell":2}` events.
+*/
+func (raster *SpikeRaster) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(raster.Events)
+}
+
+// rasterMagic starts every binary raster, followed by the format version.
+var rasterMagic = []byte("SPKR")
+
+const rasterBinaryVersion byte = 1
+
+/*
+WriteBinary writes the raster in a compact binary format:
+
+	"SPKR" | version byte | uvarint event count | events
+
+Each event is a uvarint of how many steps it came after the previous event,
+then a uvarint cell ID. Runs only step forward, so most events take two or
+three bytes.
+*/
+func (raster *SpikeRaster) WriteBinary(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	writer.Write(rasterMagic)
+	writer.WriteByte(rasterBinaryVersion)
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(x uint64) {
+		n := binary.PutUvarint(buf, x)
+		writer.Write(buf[:n])
+	}
+	writeUvarint(uint64(len(raster.Events)))
+	lastStep := 0
+	for _, event := range raster.Events {
+		if event.Step < lastStep {
+			return errors.New("Raster events must be in step order to write them as binary")
+		}
+		writeUvarint(uint64(event.Step - lastStep))
+		writeUvarint(uint64(event.Cell))
+		lastStep = event.Step
+	}
+	return writer.Flush()
+}
+
+/*
+ReadSpikeRasterBinary reads a raster written by WriteBinary.
+*/
+func ReadSpikeRasterBinary(r io.Reader) (*SpikeRaster, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, len(rasterMagic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(rasterMagic)]) != string(rasterMagic) {
+		return nil, errors.New("Not a binary spike raster")
+	}
+	if header[len(rasterMagic)] != rasterBinaryVersion {
+		return nil, errors.New("Unsupported spike raster version " + strconv.Itoa(int(header[len(rasterMagic)])))
+	}
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	raster := NewSpikeRaster()
+	step := 0
+	for i := uint64(0); i < count; i++ {
+		delta, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		cellID, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		step += int(delta)
+		raster.Events = append(raster.Events, SpikeEvent{Step: step, Cell: CellID(cellID)})
+	}
+	return raster, nil
+}
+
+/*
+SaveToFile writes the raster to a file in the format matching its extension:
+`.csv`, `.json`, or the binary format for anything else.
+*/
+func (raster *SpikeRaster) SaveToFile(filename string) (err error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	switch filepath.Ext(filename) {
+	case ".csv":
+		return raster.WriteCSV(file)
+	case ".json":
+		return raster.WriteJSON(file)
+	default:
+		return raster.WriteBinary(file)
+	}
+}
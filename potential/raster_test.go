@@ -0,0 +1,58 @@
+package potential
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SpikeRaster(t *testing.T) {
+	t.Run("records which cells fired on which step", func(t *testing.T) {
+		// a -> b -> c
+		network := NewNetwork()
+		network.Laws.NoiseRatio = 0
+		network.Laws.FiringIterationsPerSample = 1
+		a := NewCell(network)
+		b := NewCell(network)
+		c := NewCell(network)
+		network.linkCells(a.ID, b.ID).Millivolts = network.Laws.ActualSynapseMax()
+		network.linkCells(b.ID, c.ID).Millivolts = network.Laws.ActualSynapseMax()
+		raster := NewSpikeRaster()
+		network.AddObserver(raster)
+
+		FireNetworkUntilDone(network, FiringPattern{a.ID: 1})
+
+		assert.Equal(t, []SpikeEvent{{Step: 1, Cell: b.ID}, {Step: 2, Cell: c.ID}}, raster.Events)
+	})
+
+	raster := &SpikeRaster{Events: []SpikeEvent{{1, 4}, {1, 7}, {3, 300}}}
+
+	t.Run("writes csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, raster.WriteCSV(&buf))
+		assert.Equal(t, "step,cell\n1,4\n1,7\n3,300\n", buf.String())
+	})
+	t.Run("writes json", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, raster.WriteJSON(&buf))
+		assert.Equal(t, `[{"step":1,"cell":4},{"step":1,"cell":7},{"step":3,"cell":300}]`+"\n", buf.String())
+	})
+	t.Run("reads back what it wrote as binary", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, raster.WriteBinary(&buf))
+		assert.Equal(t, 4+1+1+2+2+3, buf.Len())
+		read, err := ReadSpikeRasterBinary(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, raster.Events, read.Events)
+	})
+	t.Run("will not write binary out of step order", func(t *testing.T) {
+		var buf bytes.Buffer
+		backwards := &SpikeRaster{Events: []SpikeEvent{{3, 1}, {1, 1}}}
+		assert.Error(t, backwards.WriteBinary(&buf))
+	})
+	t.Run("will not read something that is not a raster", func(t *testing.T) {
+		_, err := ReadSpikeRasterBinary(bytes.NewBufferString("nurtrace"))
+		assert.Error(t, err)
+	})
+}
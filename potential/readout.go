@@ -0,0 +1,187 @@
+package potential
+
+import (
+	"errors"
+	"log"
+)
+
+/*
+Readout is a linear classifier from the cells that fired to an output value,
+like the readout layer of a liquid state machine. The network is left alone as
+a random reservoir, and only the readout learns.
+
+Train one with TrainReadout, and set it on the vocab to have Sample use it
+instead of the closest OutputCollection. It is saved with the vocab. Train it
+again after the network trains more, because the cells will fire differently.
+*/
+type Readout struct {
+	// Cells are the cells whose fire counts are the inputs, in weight order.
+	Cells []CellID
+	// Outputs are the values the readout chooses from, in order.
+	Outputs []OutputValue
+	/*
+		Weights has a row for each output, with a weight for each cell and then
+		a bias. The output with the highest score wins.
+	*/
+	Weights [][]float64
+}
+
+/*
+ReadoutOptions are the settings for TrainReadout.
+*/
+type ReadoutOptions struct {
+	// Epochs is the most times to go through the samples.
+	Epochs int
+	// LearningRate is how much each mistake moves the weights.
+	LearningRate float64
+}
+
+/*
+DefaultReadoutOptions are the settings TrainReadout usually needs.
+*/
+func DefaultReadoutOptions() ReadoutOptions {
+	return ReadoutOptions{
+		Epochs:       100,
+		LearningRate: 0.1,
+	}
+}
+
+/*
+TrainReadout fires every sample in the vocab through a frozen copy of the
+network, then fits a multiclass perceptron from the fire counts of each cell to
+the sample's output. The network itself is not changed.
+
+It stops early once every sample is classified correctly. Returns the readout
+and the ratio of samples it gets right.
+*/
+func TrainReadout(vocab *Vocabulary, opts ReadoutOptions) (readout *Readout, accuracy float64, err error) {
+	if len(vocab.Samples) == 0 {
+		return nil, 0, errors.New("Vocab has no samples to train the readout with")
+	}
+
+	frozen := CloneNetwork(vocab.Net)
+	frozen.Laws.PlasticityRule = "none"
+	frozen.Laws.SynapseDecayInterval = 0
+
+	patterns := make([]FiringPattern, len(vocab.Samples))
+	cellSet := make(FiringPattern)
+	for i, s := range vocab.Samples {
+		frozen.ResetForTraining()
+		patterns[i] = FireNetworkUntilDone(frozen, GetInputPatternForInputs(vocab, s.inputs))
+		for cellID := range patterns[i] {
+			cellSet[cellID] = 1
+		}
+	}
+
+	readout = &Readout{Cells: sortedPatternCells(cellSet)}
+	outputIndex := make(map[OutputValue]int)
+	for _, output := range vocab.sortedOutputs() {
+		outputIndex[output.Value] = len(readout.Outputs)
+		readout.Outputs = append(readout.Outputs, output.Value)
+	}
+	for _, s := range vocab.Samples {
+		if _, ok := outputIndex[s.output]; !ok {
+			return nil, 0, errors.New("Sample output " + string(s.output) + " is not in the vocab")
+		}
+	}
+	readout.Weights = make([][]float64, len(readout.Outputs))
+	for o := range readout.Weights {
+		readout.Weights[o] = make([]float64, len(readout.Cells)+1)
+	}
+
+	features := make([][]float64, len(patterns))
+	for i, fp := range patterns {
+		features[i] = readout.features(fp)
+	}
+
+	correct := 0
+	for epoch := 0; epoch < opts.Epochs; epoch++ {
+		correct = 0
+		for i, s := range vocab.Samples {
+			want := outputIndex[s.output]
+			got := readout.best(features[i])
+			if got == want {
+				correct++
+				continue
+			}
+			for f, x := range features[i] {
+				readout.Weights[want][f] += opts.LearningRate * x
+				readout.Weights[got][f] -= opts.LearningRate * x
+			}
+		}
+		if correct == len(vocab.Samples) {
+			log.Println("Readout fit all samples after", epoch+1, "epochs")
+			break
+		}
+	}
+	// the last epoch may have fixed some mistakes, so count again
+	correct = 0
+	for i, s := range vocab.Samples {
+		if readout.best(features[i]) == outputIndex[s.output] {
+			correct++
+		}
+	}
+	accuracy = float64(correct) / float64(len(vocab.Samples))
+	return readout, accuracy, nil
+}
+
+// features are the fire counts of the readout's cells, and 1 for the bias.
+func (readout *Readout) features(fp FiringPattern) []float64 {
+	x := make([]float64, len(readout.Cells)+1)
+	for i, cellID := range readout.Cells {
+		x[i] = float64(fp[cellID])
+	}
+	x[len(readout.Cells)] = 1
+	return x
+}
+
+// best is the index of the output with the highest score. Ties go to the first.
+func (readout *Readout) best(x []float64) int {
+	best := 0
+	bestScore := 0.0
+	for o, weights := range readout.Weights {
+		score := 0.0
+		for f, w := range weights {
+			score += w * x[f]
+		}
+		if o == 0 || score > bestScore {
+			best = o
+			bestScore = score
+		}
+	}
+	return best
+}
+
+/*
+Classify returns the output value the readout picks for a firing pattern. ok is
+false when the readout has no outputs.
+*/
+func (readout *Readout) Classify(fp FiringPattern) (value OutputValue, ok bool) {
+	if len(readout.Outputs) == 0 || len(readout.Weights) != len(readout.Outputs) {
+		return value, false
+	}
+	return readout.Outputs[readout.best(readout.features(fp))], true
+}
+
+/*
+remap points the readout at cells that have new IDs after the network was
+compacted, and drops the weights of the cells that are gone.
+*/
+func (readout *Readout) remap(cellIDs map[CellID]CellID) {
+	cells := make([]CellID, 0, len(readout.Cells))
+	kept := make([]int, 0, len(readout.Cells))
+	for i, cellID := range readout.Cells {
+		if newID, ok := cellIDs[cellID]; ok {
+			cells = append(cells, newID)
+			kept = append(kept, i)
+		}
+	}
+	for o, weights := range readout.Weights {
+		remapped := make([]float64, 0, len(kept)+1)
+		for _, i := range kept {
+			remapped = append(remapped, weights[i])
+		}
+		readout.Weights[o] = append(remapped, weights[len(weights)-1])
+	}
+	readout.Cells = cells
+}
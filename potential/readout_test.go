@@ -0,0 +1,74 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Readout(t *testing.T) {
+	// q fires a -> b, r fires c -> d
+	var network *Network
+	var vocab *Vocabulary
+	var a, b, c, d *Cell
+	var ab *Synapse
+	before := func() {
+		network = NewNetwork()
+		network.Laws.NoiseRatio = 0
+		a = NewCell(network)
+		b = NewCell(network)
+		c = NewCell(network)
+		d = NewCell(network)
+		ab = network.linkCells(a.ID, b.ID)
+		ab.Millivolts = network.Laws.ActualSynapseMax()
+		network.linkCells(c.ID, d.ID).Millivolts = network.Laws.ActualSynapseMax()
+		vocab = NewVocabulary(network)
+		vocab.Inputs["q"] = &VocabUnit{Value: "q", InputCells: FiringPattern{a.ID: 1}}
+		vocab.Inputs["r"] = &VocabUnit{Value: "r", InputCells: FiringPattern{c.ID: 1}}
+		// the output patterns are backwards, so only the readout gets it right
+		vocab.Outputs["x"] = &OutputCollection{Value: "x", FirePattern: FiringPattern{d.ID: 1}}
+		vocab.Outputs["y"] = &OutputCollection{Value: "y", FirePattern: FiringPattern{b.ID: 1}}
+		err := vocab.AddTrainingData([]byte(`[{"InputText":"q","ExpectedOutput":"x"},{"InputText":"r","ExpectedOutput":"y"}]`))
+		assert.NoError(t, err)
+	}
+
+	t.Run("learns to tell the samples apart without changing the network", func(t *testing.T) {
+		before()
+		mv := ab.Millivolts
+		readout, accuracy, err := TrainReadout(vocab, DefaultReadoutOptions())
+		assert.NoError(t, err)
+		assert.Equal(t, 1.0, accuracy)
+		assert.Equal(t, []OutputValue{"x", "y"}, readout.Outputs)
+		assert.Equal(t, mv, ab.Millivolts)
+
+		value, ok := readout.Classify(FiringPattern{b.ID: 3})
+		assert.True(t, ok)
+		assert.Equal(t, OutputValue("x"), value)
+	})
+	t.Run("is used by Sample instead of the closest output", func(t *testing.T) {
+		before()
+		assert.Equal(t, "y", Sample("q", vocab, 1))
+		readout, _, err := TrainReadout(vocab, DefaultReadoutOptions())
+		assert.NoError(t, err)
+		vocab.Readout = readout
+		assert.Equal(t, "x", Sample("q", vocab, 1))
+		assert.Equal(t, "y", Sample("r", vocab, 1))
+	})
+	t.Run("follows the cells when the vocab is remapped", func(t *testing.T) {
+		before()
+		readout, _, err := TrainReadout(vocab, DefaultReadoutOptions())
+		assert.NoError(t, err)
+		vocab.Readout = readout
+		vocab.RemapCells(map[CellID]CellID{b.ID: 10, d.ID: 11})
+		assert.Equal(t, []CellID{10, 11}, readout.Cells)
+		assert.Equal(t, 3, len(readout.Weights[0]))
+		value, _ := readout.Classify(FiringPattern{11: 1})
+		assert.Equal(t, OutputValue("y"), value)
+	})
+	t.Run("needs samples", func(t *testing.T) {
+		before()
+		vocab.ClearSamples()
+		_, _, err := TrainReadout(vocab, DefaultReadoutOptions())
+		assert.Error(t, err)
+	})
+}
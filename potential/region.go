@@ -0,0 +1,280 @@
+package potential
+
+import (
+	"log"
+	"sort"
+)
+
+/*
+Region is a named group of cells in a network, like a sensory area or a layer.
+Cells join a region with `Network.SetCellRegion` or by growing them with
+`Network.GrowRegion`.
+
+Connections shape how the region's cells grow synapses. It is the relative
+chance that a synapse grown from this region lands on a cell in each other
+region, by name - `{"sensory": 1, "motor": 3}` sends three times as many
+synapses to motor cells as to sensory cells, and none anywhere else. With no
+connections, synapses grow onto any cell in the network, as usual.
+*/
+type Region struct {
+	Name        string
+	Connections map[string]float64
+}
+
+/*
+AddRegion adds a region to the network, or returns it if it already exists.
+*/
+func (network *Network) AddRegion(name string) *Region {
+	if network.Regions == nil {
+		network.Regions = make(map[string]*Region)
+	}
+	if region, ok := network.Regions[name]; ok {
+		return region
+	}
+	region := &Region{Name: name, Connections: make(map[string]float64)}
+	network.Regions[name] = region
+	return region
+}
+
+/*
+Connect sets the relative chance of this region growing synapses onto cells
+in another region. Zero stops it growing any there.
+*/
+func (region *Region) Connect(to string, weight float64) {
+	if weight <= 0 {
+		delete(region.Connections, to)
+		return
+	}
+	region.Connections[to] = weight
+}
+
+/*
+SetCellRegion puts a cell in a region, adding the region if it is new. An empty
+region takes the cell out of any region. Use this rather than setting
+`Cell.Region`, so the network knows to look again at which cells are where.
+*/
+func (network *Network) SetCellRegion(cellID CellID, region string) {
+	if region != "" {
+		network.AddRegion(region)
+	}
+	network.GetCell(cellID).Region = region
+	network.regionCells = nil
+}
+
+/*
+CellsInRegion returns the IDs of the cells in a region, in order.
+*/
+func (network *Network) CellsInRegion(region string) []CellID {
+	if network.regionCells == nil {
+		network.regionCells = make(map[string][]CellID)
+		for _, cell := range network.Cells {
+			if cell == nil { // pruned
+				continue
+			}
+			network.regionCells[cell.Region] = append(network.regionCells[cell.Region], cell.ID)
+		}
+	}
+	return network.regionCells[region]
+}
+
+/*
+randomCellInRegion picks a cell in the region. When the region has no cells, it
+falls back to any cell in the network.
+*/
+func (network *Network) randomCellInRegion(region string) CellID {
+	cells := network.CellsInRegion(region)
+	if len(cells) == 0 {
+		return network.RandomCellKey()
+	}
+	return cells[network.randomIntBetween(0, len(cells)-1)]
+}
+
+/*
+randomTargetFor picks a cell for a synapse from the given cell to grow onto,
+following the connections of the cell's region. Cells outside a region, and
+regions without connections, grow onto any cell.
+*/
+func (network *Network) randomTargetFor(fromCellID CellID) CellID {
+	from := network.GetCell(fromCellID)
+	if from == nil || from.Region == "" {
+		return network.RandomCellKey()
+	}
+	region, ok := network.Regions[from.Region]
+	if !ok || len(region.Connections) == 0 {
+		return network.RandomCellKey()
+	}
+
+	names := make([]string, 0, len(region.Connections))
+	total := 0.0
+	for name, weight := range region.Connections {
+		names = append(names, name)
+		total += weight
+	}
+	sort.Strings(names)
+	pick := network.rng.Float64() * total
+	for _, name := range names {
+		pick -= region.Connections[name]
+		if pick < 0 {
+			return network.randomCellInRegion(name)
+		}
+	}
+	return network.randomCellInRegion(names[len(names)-1])
+}
+
+/*
+GrowRegion is Grow for a single region. The new neurons join the region, and the
+random synapses and deep paths all start from cells in the region. Where the
+synapses end up follows the region's connections, except for the cells in the
+middle of deep paths, which can be anywhere.
+*/
+func (network *Network) GrowRegion(region string, neuronsToAdd, synapsesPerNewNeuron, synapsesToAdd int) {
+	network.AddRegion(region)
+	someSynapses := synapsesPerNewNeuron / 4
+
+	var addedNeurons []*Cell
+	for i := 0; i < neuronsToAdd; i++ {
+		cell := NewCell(network)
+		cell.Region = region
+		addedNeurons = append(addedNeurons, cell)
+	}
+	network.regionCells = nil
+	network.assignCellTypes(addedNeurons)
+	for _, cell := range addedNeurons {
+		network.growSynapsesFrom(cell.ID, someSynapses)
+	}
+
+	for i := 0; i < synapsesToAdd; i++ {
+		network.growSynapsesFrom(network.randomCellInRegion(region), 1)
+	}
+
+	for i := 0; i < neuronsToAdd; i++ {
+		if i%50 == 0 {
+			log.Println("growing deep synapses in region", region, "progress=", i, "/", neuronsToAdd)
+		}
+		from := network.randomCellInRegion(region)
+		to := network.randomTargetFor(from)
+		if to == from || !network.CellExists(to) {
+			continue
+		}
+		network.GrowPathBetween(from, to, network.Laws.ComputedSynapsesPerCell())
+	}
+}
+
+/*
+GrowBetweenRegions grows synapses from random cells in one region onto random
+cells in another, ignoring the regions' connections.
+*/
+func (network *Network) GrowBetweenRegions(from, to string, synapsesToAdd int) {
+	if len(network.CellsInRegion(from)) == 0 || len(network.CellsInRegion(to)) == 0 {
+		return
+	}
+	if from == to && len(network.CellsInRegion(from)) < 2 {
+		return // thy cell shannot activate thyself
+	}
+	for i := 0; i < synapsesToAdd; {
+		sender := network.randomCellInRegion(from)
+		receiver := network.randomCellInRegion(to)
+		if sender == receiver {
+			continue
+		}
+		network.growSynapse(sender, receiver)
+		i++
+	}
+}
+
+/*
+GrowPathsBetweenRegions grows paths, like GrowPathBetween, from random cells in
+one region to random cells in another.
+*/
+func (network *Network) GrowPathsBetweenRegions(from, to string, paths, minSynapses int) {
+	if len(network.CellsInRegion(from)) == 0 || len(network.CellsInRegion(to)) == 0 {
+		return
+	}
+	for i := 0; i < paths; i++ {
+		start := network.randomCellInRegion(from)
+		end := network.randomCellInRegion(to)
+		if start == end {
+			continue
+		}
+		network.GrowPathBetween(start, end, minSynapses)
+	}
+}
+
+/*
+RegionSummary counts the cells in a region, and its synapses by the region
+they go to.
+*/
+type RegionSummary struct {
+	Name     string
+	Cells    int
+	Synapses map[string]int
+}
+
+/*
+SummarizeRegions counts the cells and synapses of every region, in name order.
+Cells outside any region are summarized under an empty name, first.
+*/
+func (network *Network) SummarizeRegions() []*RegionSummary {
+	byName := make(map[string]*RegionSummary)
+	summarize := func(name string) *RegionSummary {
+		if _, ok := byName[name]; !ok {
+			byName[name] = &RegionSummary{Name: name, Synapses: make(map[string]int)}
+		}
+		return byName[name]
+	}
+	for name := range network.Regions {
+		summarize(name)
+	}
+	for _, cell := range network.Cells {
+		if cell == nil { // pruned
+			continue
+		}
+		summarize(cell.Region).Cells++
+	}
+	for _, synapse := range network.Synapses {
+		if synapse == nil { // pruned
+			continue
+		}
+		from := network.GetCell(synapse.FromNeuronAxon).Region
+		to := network.GetCell(synapse.ToNeuronDendrite).Region
+		summarize(from).Synapses[to]++
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	summaries := make([]*RegionSummary, len(names))
+	for i, name := range names {
+		summaries[i] = byName[name]
+	}
+	return summaries
+}
+
+/*
+Print logs the region's counts.
+*/
+func (summary *RegionSummary) Print() {
+	name := summary.Name
+	if name == "" {
+		name = "(no region)"
+	}
+	total := 0
+	for _, count := range summary.Synapses {
+		total += count
+	}
+	log.Println(name, "cells=", summary.Cells, "synapses=", total)
+	to := make([]string, 0, len(summary.Synapses))
+	for region := range summary.Synapses {
+		to = append(to, region)
+	}
+	sort.Strings(to)
+	for _, region := range to {
+		if region == "" {
+			log.Println("  -> (no region)", summary.Synapses[region])
+			continue
+		}
+		log.Println("  ->", region, summary.Synapses[region])
+	}
+}
@@ -0,0 +1,124 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Regions(t *testing.T) {
+	t.Run("cells join regions", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		NewCell(network)
+		network.SetCellRegion(b.ID, "motor")
+		network.SetCellRegion(a.ID, "motor")
+		assert.Equal(t, []CellID{a.ID, b.ID}, network.CellsInRegion("motor"))
+		assert.Contains(t, network.Regions, "motor")
+
+		network.SetCellRegion(a.ID, "")
+		assert.Equal(t, []CellID{b.ID}, network.CellsInRegion("motor"))
+	})
+	t.Run("synapses from a region only grow where its connections go", func(t *testing.T) {
+		network := NewNetwork()
+		network.SetSeed(3)
+		network.Grow(20, 8, 0)
+		network.GrowRegion("motor", 20, 8, 0)
+		sensory := network.AddRegion("sensory")
+		sensory.Connect("motor", 1)
+		for i := 0; i < 10; i++ {
+			network.SetCellRegion(NewCell(network).ID, "sensory")
+		}
+		before := len(network.Synapses)
+		for _, cellID := range network.CellsInRegion("sensory") {
+			network.growSynapsesFrom(cellID, 3)
+		}
+		network.GrowRegion("sensory", 5, 8, 10)
+
+		assert.Equal(t, 15, len(network.CellsInRegion("sensory")))
+		for _, synapse := range network.Synapses[before : before+30] {
+			assert.Equal(t, "motor", network.GetCell(synapse.ToNeuronDendrite).Region, "synapse %d", synapse.ID)
+		}
+		ok, integrity := CheckIntegrity(network)
+		assert.True(t, ok, integrity)
+	})
+	t.Run("connections are weighted", func(t *testing.T) {
+		network := NewNetwork()
+		network.SetSeed(4)
+		for i := 0; i < 10; i++ {
+			network.SetCellRegion(NewCell(network).ID, "a")
+			network.SetCellRegion(NewCell(network).ID, "b")
+		}
+		network.Regions["a"].Connect("a", 1)
+		network.Regions["a"].Connect("b", 3)
+		counts := make(map[string]int)
+		from := network.CellsInRegion("a")[0]
+		for i := 0; i < 1000; i++ {
+			counts[network.GetCell(network.randomTargetFor(from)).Region]++
+		}
+		assert.InDelta(t, 750, counts["b"], 60)
+		assert.Equal(t, 1000, counts["a"]+counts["b"])
+	})
+	t.Run("grows synapses and paths between regions", func(t *testing.T) {
+		network := NewNetwork()
+		network.SetSeed(5)
+		for i := 0; i < 5; i++ {
+			network.SetCellRegion(NewCell(network).ID, "in")
+			network.SetCellRegion(NewCell(network).ID, "out")
+		}
+		network.GrowBetweenRegions("in", "out", 7)
+
+		summaries := network.SummarizeRegions()
+		assert.Equal(t, 2, len(summaries))
+		assert.Equal(t, "in", summaries[0].Name)
+		assert.Equal(t, 5, summaries[0].Cells)
+		assert.Equal(t, map[string]int{"out": 7}, summaries[0].Synapses)
+
+		network.GrowPathsBetweenRegions("in", "out", 2, 1)
+		assert.True(t, len(network.Synapses) > 7)
+	})
+	t.Run("regions are saved and cloned with the network", func(t *testing.T) {
+		network := NewNetwork()
+		network.GrowRegion("motor", 3, 0, 0)
+		network.Regions["motor"].Connect("motor", 2)
+		clone := CloneNetwork(network)
+		assert.Equal(t, network.Regions, clone.Regions)
+		assert.Equal(t, 3, len(clone.CellsInRegion("motor")))
+
+		assert.NoError(t, network.SaveToFile("_network_regions.test.json"))
+		net2, err := LoadNetworkFromFile("_network_regions.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, network.Regions, net2.Regions)
+		assert.Equal(t, network.CellsInRegion("motor"), net2.CellsInRegion("motor"))
+	})
+	t.Run("vocab inputs come from the sensory region", func(t *testing.T) {
+		network := NewNetwork()
+		network.SetSeed(6)
+		network.Grow(30, 4, 0)
+		network.GrowRegion("sensory", 10, 4, 0)
+		vocab := NewVocabulary(network)
+		vocab.InputRegion = "sensory"
+		unit := NewVocabUnit("a")
+		unit.InitRandomInputs(vocab)
+		for cellID := range unit.InputCells {
+			assert.Equal(t, "sensory", network.GetCell(cellID).Region)
+		}
+	})
+	t.Run("growing gives up on a cell whose region only connects to itself", func(t *testing.T) {
+		network := NewNetwork()
+		network.SetSeed(2)
+		for i := 0; i < 10; i++ {
+			NewCell(network)
+		}
+		network.SetCellRegion(5, "solo")
+		network.Regions["solo"].Connect("solo", 1)
+		network.Grow(10, 4, 10)
+		assert.Equal(t, 20, len(network.Cells))
+
+		alone := NewNetwork()
+		NewCell(alone)
+		alone.GrowRandomSynapses(5)
+		assert.Equal(t, 0, len(alone.Synapses))
+	})
+}
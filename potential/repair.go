@@ -0,0 +1,225 @@
+package potential
+
+import (
+	"fmt"
+	"log"
+)
+
+/*
+RepairReport lists what Repair changed to give a network good integrity.
+*/
+type RepairReport struct {
+	// RenumberedCells had an ID that was not their place in the network.
+	RenumberedCells []CellID
+	// RenumberedSynapses had an ID that was not their place in the network.
+	RenumberedSynapses []SynapseID
+	// RemovedAxonSynapses are synapses taken out of the cells' axons, because
+	// they do not exist or do not come from that cell.
+	RemovedAxonSynapses map[CellID][]SynapseID
+	// RemovedDendriteSynapses are synapses taken out of the cells' dendrites,
+	// because they do not exist or do not go to that cell.
+	RemovedDendriteSynapses map[CellID][]SynapseID
+	// PrunedSynapses connected a cell that does not exist.
+	PrunedSynapses []SynapseID
+	// AddedAxonSynapses are synapses from the cells that were missing from
+	// their axons.
+	AddedAxonSynapses map[CellID][]SynapseID
+	// AddedDendriteSynapses are synapses to the cells that were missing from
+	// their dendrites.
+	AddedDendriteSynapses map[CellID][]SynapseID
+	// ResignedSynapses had their sign flipped to match the type of their cell.
+	ResignedSynapses []SynapseID
+	// ClampedSynapses had millivolts past what the laws allow, and were set to
+	// the nearest allowed value.
+	ClampedSynapses []SynapseID
+}
+
+func newRepairReport() RepairReport {
+	return RepairReport{
+		RemovedAxonSynapses:     make(map[CellID][]SynapseID),
+		RemovedDendriteSynapses: make(map[CellID][]SynapseID),
+		AddedAxonSynapses:       make(map[CellID][]SynapseID),
+		AddedDendriteSynapses:   make(map[CellID][]SynapseID),
+	}
+}
+
+/*
+Fixed is how many things Repair changed.
+*/
+func (report RepairReport) Fixed() int {
+	fixed := len(report.RenumberedCells) + len(report.RenumberedSynapses) +
+		len(report.PrunedSynapses) + len(report.ResignedSynapses) + len(report.ClampedSynapses)
+	for _, byCell := range []map[CellID][]SynapseID{
+		report.RemovedAxonSynapses, report.RemovedDendriteSynapses,
+		report.AddedAxonSynapses, report.AddedDendriteSynapses,
+	} {
+		for _, synapses := range byCell {
+			fixed += len(synapses)
+		}
+	}
+	return fixed
+}
+
+/*
+Print logs what was repaired.
+*/
+func (report RepairReport) Print() {
+	if report.Fixed() == 0 {
+		log.Println("Nothing needed repair")
+		return
+	}
+	log.Println("Repaired", report.Fixed(), "problems")
+	if len(report.RenumberedCells) > 0 {
+		log.Println("  gave cells the ID of their place in the network", report.RenumberedCells)
+	}
+	if len(report.RenumberedSynapses) > 0 {
+		log.Println("  gave synapses the ID of their place in the network", report.RenumberedSynapses)
+	}
+	printByCell := func(what string, byCell map[CellID][]SynapseID) {
+		for _, cellID := range sortedCellIDsOf(byCell) {
+			log.Println(" ", what, "cell=", cellID, "synapses=", byCell[cellID])
+		}
+	}
+	printByCell("removed missing axon synapses from", report.RemovedAxonSynapses)
+	printByCell("removed missing dendrite synapses from", report.RemovedDendriteSynapses)
+	printByCell("added axon synapses back to", report.AddedAxonSynapses)
+	printByCell("added dendrite synapses back to", report.AddedDendriteSynapses)
+	if len(report.PrunedSynapses) > 0 {
+		log.Println("  pruned synapses to missing cells", report.PrunedSynapses)
+	}
+	if len(report.ResignedSynapses) > 0 {
+		log.Println("  flipped the sign of synapses to match their cell type", report.ResignedSynapses)
+	}
+	if len(report.ClampedSynapses) > 0 {
+		log.Println("  clamped the millivolts of synapses to the laws", report.ClampedSynapses)
+	}
+}
+
+func sortedCellIDsOf(byCell map[CellID][]SynapseID) []CellID {
+	cells := make(map[CellID]bool, len(byCell))
+	for cellID := range byCell {
+		cells[cellID] = true
+	}
+	return sortedCellIDs(cells)
+}
+
+/*
+Repair fixes everything CheckIntegrity finds wrong with a network, so it can be
+saved and loaded again:
+
+  - cells and synapses get the ID of their place in the network, which is what
+    everything else refers to them by
+  - cells lose references to synapses that do not exist, or that do not connect
+    to them
+  - synapses to or from cells that do not exist are pruned
+  - cells get back references to synapses that connect to them
+  - synapses with a sign their cell type does not allow are flipped
+
+Synapses with more millivolts than the laws allow are clamped too, although
+that is only a warning. Cells are not pruned, even if they are left without
+synapses. Self-loops, duplicate synapses and cells without synapses are only
+warnings, so they are left.
+*/
+func Repair(network *Network) RepairReport {
+	report := newRepairReport()
+	minMillivolts := network.Laws.ActualSynapseMin()
+	maxMillivolts := network.Laws.ActualSynapseMax()
+
+	for cellID, cell := range network.Cells {
+		if cell != nil && cell.ID != CellID(cellID) {
+			cell.ID = CellID(cellID)
+			report.RenumberedCells = append(report.RenumberedCells, cell.ID)
+		}
+	}
+	for synapseID, synapse := range network.Synapses {
+		if synapse != nil && synapse.ID != SynapseID(synapseID) {
+			synapse.ID = SynapseID(synapseID)
+			report.RenumberedSynapses = append(report.RenumberedSynapses, synapse.ID)
+		}
+	}
+
+	for _, cell := range network.Cells {
+		if cell == nil { // pruned
+			continue
+		}
+		if cell.AxonSynapses == nil {
+			cell.AxonSynapses = make(map[SynapseID]bool)
+		}
+		if cell.DendriteSynapses == nil {
+			cell.DendriteSynapses = make(map[SynapseID]bool)
+		}
+		for _, synapseID := range sortedSynapseIDs(cell.AxonSynapses) {
+			if !network.SynExists(synapseID) || network.GetSyn(synapseID).FromNeuronAxon != cell.ID {
+				delete(cell.AxonSynapses, synapseID)
+				report.RemovedAxonSynapses[cell.ID] = append(report.RemovedAxonSynapses[cell.ID], synapseID)
+			}
+		}
+		for _, synapseID := range sortedSynapseIDs(cell.DendriteSynapses) {
+			if !network.SynExists(synapseID) || network.GetSyn(synapseID).ToNeuronDendrite != cell.ID {
+				delete(cell.DendriteSynapses, synapseID)
+				report.RemovedDendriteSynapses[cell.ID] = append(report.RemovedDendriteSynapses[cell.ID], synapseID)
+			}
+		}
+	}
+
+	for synapseID, synapse := range network.Synapses {
+		if synapse == nil { // pruned
+			continue
+		}
+		if !network.CellExists(synapse.FromNeuronAxon) || !network.CellExists(synapse.ToNeuronDendrite) {
+			if network.CellExists(synapse.FromNeuronAxon) {
+				delete(network.GetCell(synapse.FromNeuronAxon).AxonSynapses, synapse.ID)
+			}
+			if network.CellExists(synapse.ToNeuronDendrite) {
+				delete(network.GetCell(synapse.ToNeuronDendrite).DendriteSynapses, synapse.ID)
+			}
+			network.synMux.Lock()
+			network.Synapses[synapseID] = nil
+			network.synMux.Unlock()
+			report.PrunedSynapses = append(report.PrunedSynapses, synapse.ID)
+			continue
+		}
+
+		from := network.GetCell(synapse.FromNeuronAxon)
+		if !from.AxonSynapses[synapse.ID] {
+			from.AxonSynapses[synapse.ID] = true
+			report.AddedAxonSynapses[from.ID] = append(report.AddedAxonSynapses[from.ID], synapse.ID)
+		}
+		to := network.GetCell(synapse.ToNeuronDendrite)
+		if !to.DendriteSynapses[synapse.ID] {
+			to.DendriteSynapses[synapse.ID] = true
+			report.AddedDendriteSynapses[to.ID] = append(report.AddedDendriteSynapses[to.ID], synapse.ID)
+		}
+		if !from.Type.allows(synapse.Millivolts) {
+			synapse.Millivolts = from.Type.signed(synapse.Millivolts)
+			report.ResignedSynapses = append(report.ResignedSynapses, synapse.ID)
+		}
+		if synapse.Millivolts < minMillivolts {
+			synapse.Millivolts = minMillivolts
+			report.ClampedSynapses = append(report.ClampedSynapses, synapse.ID)
+		} else if synapse.Millivolts > maxMillivolts {
+			synapse.Millivolts = maxMillivolts
+			report.ClampedSynapses = append(report.ClampedSynapses, synapse.ID)
+		}
+	}
+
+	return report
+}
+
+/*
+LoadNetworkFromFileRepaired is LoadNetworkFromFile for networks with bad
+integrity. It repairs the network before checking it, instead of refusing to
+load it, and reports what was repaired. Save the network to keep the repairs.
+*/
+func LoadNetworkFromFileRepaired(filepath string) (*Network, RepairReport, error) {
+	network, _, err := loadNetworkFromFile(filepath, false)
+	if err != nil {
+		return network, newRepairReport(), err
+	}
+	report := Repair(network)
+	if ok, integrity := CheckIntegrity(network); !ok {
+		integrity.Print()
+		return network, report, fmt.Errorf("Cannot repair network from file %s", filepath)
+	}
+	return network, report, nil
+}
@@ -0,0 +1,101 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Repair(t *testing.T) {
+	t.Run("removes references to synapses that do not exist", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		ab := network.linkCells(a.ID, b.ID)
+		a.AxonSynapses[5] = true
+		b.DendriteSynapses[6] = true
+		// a does not send ab, so it should not have it as a dendrite
+		a.DendriteSynapses[ab.ID] = true
+
+		report := Repair(network)
+		assert.Equal(t, map[CellID][]SynapseID{a.ID: {5}}, report.RemovedAxonSynapses)
+		assert.Equal(t, map[CellID][]SynapseID{a.ID: {ab.ID}, b.ID: {6}}, report.RemovedDendriteSynapses)
+		assert.Equal(t, map[SynapseID]bool{ab.ID: true}, a.AxonSynapses)
+		assert.Equal(t, 0, len(a.DendriteSynapses))
+		ok, _ := CheckIntegrity(network)
+		assert.True(t, ok)
+	})
+	t.Run("prunes synapses to cells that do not exist", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		s := NewSynapse(network)
+		s.FromNeuronAxon = a.ID
+		s.ToNeuronDendrite = 11
+		a.AxonSynapses[s.ID] = true
+
+		report := Repair(network)
+		assert.Equal(t, []SynapseID{s.ID}, report.PrunedSynapses)
+		assert.False(t, network.SynExists(s.ID))
+		assert.Equal(t, 0, len(a.AxonSynapses))
+		assert.True(t, network.CellExists(a.ID), "cells are not pruned")
+	})
+	t.Run("adds back references that are missing", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		ab := network.linkCells(a.ID, b.ID)
+		delete(a.AxonSynapses, ab.ID)
+		delete(b.DendriteSynapses, ab.ID)
+
+		report := Repair(network)
+		assert.Equal(t, map[CellID][]SynapseID{a.ID: {ab.ID}}, report.AddedAxonSynapses)
+		assert.Equal(t, map[CellID][]SynapseID{b.ID: {ab.ID}}, report.AddedDendriteSynapses)
+		assert.True(t, a.AxonSynapses[ab.ID])
+		assert.True(t, b.DendriteSynapses[ab.ID])
+	})
+	t.Run("flips synapses to the sign of their cell type", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		a.Type = InhibitoryCell
+		ab := network.linkCells(a.ID, b.ID)
+		ab.Millivolts = 40
+
+		report := Repair(network)
+		assert.Equal(t, []SynapseID{ab.ID}, report.ResignedSynapses)
+		assert.Equal(t, int16(-40), ab.Millivolts)
+	})
+	t.Run("clamps millivolts to the laws", func(t *testing.T) {
+		network := NewNetwork()
+		a := NewCell(network)
+		b := NewCell(network)
+		a.Type = ExcitatoryCell
+		ab := network.linkCells(a.ID, b.ID)
+		ab.Millivolts = network.Laws.ActualSynapseMax() + 1
+
+		report := Repair(network)
+		assert.Equal(t, []SynapseID{ab.ID}, report.ClampedSynapses)
+		assert.Equal(t, network.Laws.ActualSynapseMax(), ab.Millivolts)
+		_, integrity := CheckIntegrity(network)
+		assert.Equal(t, 0, len(integrity.synapseMillivoltsOutOfRange))
+	})
+	t.Run("leaves a good network alone", func(t *testing.T) {
+		network := NewNetwork()
+		network.Grow(20, 4, 10)
+		assert.Equal(t, 0, Repair(network).Fixed())
+	})
+	t.Run("loads a network with bad integrity by repairing it", func(t *testing.T) {
+		filepath := "_network_repair.test.nur"
+		network := NewNetwork()
+		a := NewCell(network)
+		a.AxonSynapses[5] = true
+		assert.NoError(t, network.SaveToFile(filepath))
+		_, err := LoadNetworkFromFile(filepath)
+		assert.Error(t, err)
+
+		repaired, report, err := LoadNetworkFromFileRepaired(filepath)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Fixed())
+		assert.Equal(t, 0, len(repaired.GetCell(a.ID).AxonSynapses))
+	})
+}
@@ -6,7 +6,7 @@ import (
 
 /*
 Sample produces the raw string output based on seed text that was input
-by the user.
+by the user. The vocab's Readout picks the output when it has one.
 */
 func Sample(seedText string, vocab *Vocabulary, maxLength int) (output string) {
 	characters := strings.Split(string(seedText), "")
@@ -23,11 +23,27 @@ func Sample(seedText string, vocab *Vocabulary, maxLength int) (output string) {
 	cellsToFireForInputValues := GetInputPatternForInputs(vocab, inputs)
 	finalPattern := FireNetworkUntilDone(vocab.Net, cellsToFireForInputValues)
 
+	output += decodeOutput(finalPattern, vocab)
+
+	return output
+}
+
+/*
+decodeOutput turns what the network fired into an output with the vocab's
+Readout, or the closest OutputCollection when it has none.
+*/
+func decodeOutput(fp FiringPattern, vocab *Vocabulary) (output string) {
+	if vocab.Readout != nil {
+		if value, ok := vocab.Readout.Classify(fp); ok {
+			output = string(value)
+		}
+		return output
+	}
+
 	// TODO: find more than one match?
-	closest := FindClosestOutputCollection(finalPattern, vocab)
+	closest := FindClosestOutputCollection(fp, vocab)
 	if closest != nil {
-		output += string(closest.Value)
+		output = string(closest.Value)
 	}
-
 	return output
 }
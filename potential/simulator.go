@@ -0,0 +1,145 @@
+package potential
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+SimulatorEvent is sent by a Simulator after every step where cells fired.
+*/
+type SimulatorEvent struct {
+	// Step is `Network.Steps()` when the cells fired.
+	Step  int
+	Fired []CellID
+}
+
+/*
+Simulator keeps a network alive, stepping it at a steady rate instead of in
+bursts like FireNetworkUntilDone. Stimuli can be fed in at any time, and the
+cells that fire come back out as events.
+
+Send firing patterns on Inputs to fire those cells; they spread through the
+network on the following steps. Receive from Fired to see what fired. Fired is
+closed when Run returns.
+
+While the simulator runs, it owns the network. Use Do to touch the network
+from another goroutine.
+*/
+type Simulator struct {
+	Network *Network
+	// Interval is how long to wait between steps.
+	Interval time.Duration
+	Inputs   chan FiringPattern
+	Fired    chan SimulatorEvent
+	mux      sync.Mutex
+}
+
+/*
+NewSimulator is a Simulator factory. The channels are buffered by bufferSize.
+*/
+func NewSimulator(network *Network, interval time.Duration, bufferSize int) *Simulator {
+	return &Simulator{
+		Network:  network,
+		Interval: interval,
+		Inputs:   make(chan FiringPattern, bufferSize),
+		Fired:    make(chan SimulatorEvent, bufferSize),
+	}
+}
+
+/*
+Run steps the network every Interval until the context is done, and returns the
+context's error.
+
+Sending an event on Fired waits for a receiver, so a slow receiver slows the
+simulation down instead of missing events. Steps that fall behind are skipped,
+like a `time.Ticker`.
+*/
+func (sim *Simulator) Run(ctx context.Context) error {
+	defer close(sim.Fired)
+	ticker := time.NewTicker(sim.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case fp := <-sim.Inputs:
+			sim.inject(fp)
+		case <-ticker.C:
+			event, ok := sim.step()
+			if !ok {
+				continue
+			}
+			select {
+			case sim.Fired <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+/*
+inject fires the cells in the firing pattern, in order. Cells that were pruned
+are skipped.
+*/
+func (sim *Simulator) inject(fp FiringPattern) {
+	sim.mux.Lock()
+	defer sim.mux.Unlock()
+	cellIDs := make([]CellID, 0, len(fp))
+	for cellID := range fp {
+		cellIDs = append(cellIDs, cellID)
+	}
+	sort.Slice(cellIDs, func(i, j int) bool { return cellIDs[i] < cellIDs[j] })
+	for _, cellID := range cellIDs {
+		if sim.Network.CellExists(cellID) {
+			sim.Network.GetCell(cellID).FireActionPotential()
+		}
+	}
+}
+
+/*
+step steps the network once. ok is false if nothing fired, including when the
+simulator is paused.
+*/
+func (sim *Simulator) step() (event SimulatorEvent, ok bool) {
+	sim.mux.Lock()
+	defer sim.mux.Unlock()
+	if sim.Network.Disabled {
+		return event, false
+	}
+	sim.Network.Step()
+	fired := sim.Network.FiredLastStep()
+	if len(fired) == 0 {
+		return event, false
+	}
+	return SimulatorEvent{Step: sim.Network.Steps(), Fired: fired}, true
+}
+
+/*
+Pause stops stepping the network by disabling it. Inputs sent while paused are
+still fired, and spread once the simulator resumes.
+*/
+func (sim *Simulator) Pause() {
+	sim.Do(func(network *Network) { network.Disabled = true })
+}
+
+/*
+Resume starts stepping the network again.
+*/
+func (sim *Simulator) Resume() {
+	sim.Do(func(network *Network) { network.Disabled = false })
+}
+
+/*
+Do calls fn with the network between steps, so it is safe to read or change the
+network while the simulator is running.
+*/
+func (sim *Simulator) Do(fn func(network *Network)) {
+	sim.mux.Lock()
+	defer sim.mux.Unlock()
+	fn(sim.Network)
+}
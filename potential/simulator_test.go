@@ -0,0 +1,80 @@
+package potential
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Simulator(t *testing.T) {
+	// a -> b -> c
+	var network *Network
+	var a, b, c *Cell
+	before := func() {
+		network = NewNetwork()
+		a = NewCell(network)
+		b = NewCell(network)
+		c = NewCell(network)
+		network.linkCells(a.ID, b.ID).Millivolts = network.Laws.ActualSynapseMax()
+		network.linkCells(b.ID, c.ID).Millivolts = network.Laws.ActualSynapseMax()
+	}
+	receive := func(sim *Simulator) (SimulatorEvent, bool) {
+		select {
+		case event := <-sim.Fired:
+			return event, true
+		case <-time.After(200 * time.Millisecond):
+			return SimulatorEvent{}, false
+		}
+	}
+
+	t.Run("inputs spread through the network and come back as events", func(t *testing.T) {
+		before()
+		sim := NewSimulator(network, time.Millisecond, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- sim.Run(ctx) }()
+
+		sim.Inputs <- FiringPattern{a.ID: 1}
+		first, ok := receive(sim)
+		assert.True(t, ok)
+		assert.Equal(t, []CellID{b.ID}, first.Fired)
+		second, ok := receive(sim)
+		assert.True(t, ok)
+		assert.Equal(t, []CellID{c.ID}, second.Fired)
+		assert.Equal(t, first.Step+1, second.Step)
+
+		cancel()
+		assert.Equal(t, context.Canceled, <-done)
+		_, open := <-sim.Fired
+		assert.False(t, open, "Fired is closed when Run returns")
+	})
+	t.Run("pausing stops the steps until it resumes", func(t *testing.T) {
+		before()
+		sim := NewSimulator(network, time.Millisecond, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go sim.Run(ctx)
+
+		sim.Pause()
+		sim.Inputs <- FiringPattern{a.ID: 1}
+		_, ok := receive(sim)
+		assert.False(t, ok, "nothing fires while paused")
+		sim.Do(func(network *Network) {
+			assert.True(t, network.Disabled)
+		})
+
+		sim.Resume()
+		event, ok := receive(sim)
+		assert.True(t, ok)
+		assert.Equal(t, []CellID{b.ID}, event.Fired)
+	})
+	t.Run("stops when the context times out", func(t *testing.T) {
+		before()
+		sim := NewSimulator(network, time.Millisecond, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, sim.Run(ctx))
+	})
+}
@@ -0,0 +1,146 @@
+package potential
+
+import (
+	"log"
+	"math"
+)
+
+/*
+SleepOptions controls a sleep phase. See `Network.Sleep`.
+*/
+type SleepOptions struct {
+	// Vocab has the output firing patterns to replay.
+	Vocab *Vocabulary
+	// Replays is how many times each output firing pattern is replayed.
+	Replays int
+	// ReinforceAmount is how many millivolts a replayed synapse is moved away
+	// from zero.
+	ReinforceAmount int16
+	// DownscaleRatio is how much of the distance to zero every synapse that was
+	// not replayed loses.
+	DownscaleRatio float64
+	// PruneFloor is the weakest a synapse can be after downscaling without
+	// being pruned, in millivolts either side of zero.
+	PruneFloor int16
+}
+
+/*
+DefaultSleepOptions are the options Train sleeps with. Synapses are reinforced by
+the learn rate, and pruned when they are within twice the learn rate of zero.
+*/
+func DefaultSleepOptions(vocab *Vocabulary) SleepOptions {
+	learnRate := vocab.Net.Laws.SynapseLearnRate
+	return SleepOptions{
+		Vocab:           vocab,
+		Replays:         1,
+		ReinforceAmount: learnRate,
+		DownscaleRatio:  0.1,
+		PruneFloor:      learnRate * 2,
+	}
+}
+
+/*
+SleepReport counts what a sleep phase did.
+*/
+type SleepReport struct {
+	Replayed   int
+	Downscaled int
+	Pruned     int
+}
+
+/*
+Print logs the totals of the report.
+*/
+func (report SleepReport) Print() {
+	log.Println("Sleep")
+	log.Println(" ", report.Replayed, "synapses replayed")
+	log.Println(" ", report.Downscaled, "synapses downscaled")
+	log.Println(" ", report.Pruned, "synapses pruned")
+}
+
+/*
+Sleep consolidates what the network learned, as described in docs/sleep.md.
+
+First the output firing patterns from the vocab are replayed, by firing them
+like FireNetworkUntilDone does, but without noise, the plasticity rule, synapse
+decay or threshold adaptation, so only the output patterns fire and nothing is
+learned or pruned that the report does not count. The synapses that delivered
+voltage during the
+replay are reinforced. Then every other synapse is downscaled uniformly toward
+zero, and those left weaker than the prune floor are pruned. Replay protects
+the paths the network uses from the downscaling that shrinks everything else.
+
+Train sleeps every `Vocabulary.SleepEvery` merges. Clones that were already
+training when the network slept still diff against their own copy of the
+synapses, so they can undo some of the downscaling when they merge back.
+*/
+func (network *Network) Sleep(opts SleepOptions) (report SleepReport) {
+	wasDisabled := network.Disabled
+
+	// ActivationHistory tells which synapses delivered during the replay
+	history := make([]uint, len(network.Synapses))
+	for i, synapse := range network.Synapses {
+		if synapse != nil {
+			history[i] = synapse.ActivationHistory
+		}
+	}
+
+	noiseRatio := network.Laws.NoiseRatio
+	ruleName, rule := network.Laws.PlasticityRule, network.plasticity
+	decayInterval := network.Laws.SynapseDecayInterval
+	adaptation := network.Laws.ThresholdAdaptation
+	network.Laws.NoiseRatio = 0
+	network.Laws.PlasticityRule = "none"
+	network.Laws.SynapseDecayInterval = 0
+	network.Laws.ThresholdAdaptation = 0
+	for _, outColl := range opts.Vocab.sortedOutputs() {
+		for i := 0; i < opts.Replays; i++ {
+			network.ResetForTraining()
+			FireNetworkUntilDone(network, outColl.FirePattern)
+		}
+	}
+	network.ResetForTraining()
+	network.Disabled = wasDisabled
+	network.Laws.NoiseRatio = noiseRatio
+	network.Laws.PlasticityRule = ruleName
+	network.plasticity = rule
+	network.Laws.SynapseDecayInterval = decayInterval
+	network.Laws.ThresholdAdaptation = adaptation
+
+	replayed := make(map[SynapseID]bool)
+	for i, synapse := range network.Synapses {
+		if synapse == nil { // pruned
+			continue
+		}
+		// synapses added during the replay were not in the history
+		if i >= len(history) || synapse.ActivationHistory > history[i] {
+			replayed[synapse.ID] = true
+		}
+	}
+
+	// ranging over a copy, because reinforcing can add synapses and pruning
+	// removes them
+	synapses := append([]*Synapse{}, network.Synapses...)
+	for _, synapse := range synapses {
+		if synapse == nil || !network.SynExists(synapse.ID) {
+			continue
+		}
+		if replayed[synapse.ID] {
+			reinforceByAmount(synapse, opts.ReinforceAmount)
+			report.Replayed++
+			continue
+		}
+		if opts.DownscaleRatio > 0 {
+			report.Downscaled++
+			if weakenByRatio(synapse, opts.DownscaleRatio) {
+				report.Pruned++
+				continue
+			}
+		}
+		if math.Abs(float64(synapse.Millivolts)) < float64(opts.PruneFloor) {
+			network.PruneSynapse(synapse.ID)
+			report.Pruned++
+		}
+	}
+	return report
+}
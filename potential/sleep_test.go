@@ -0,0 +1,104 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sleep(t *testing.T) {
+	// a -> b is the replayed path, c -> d is idle
+	var network *Network
+	var vocab *Vocabulary
+	var a, b, c, d *Cell
+	var ab, cd *Synapse
+	before := func() {
+		network = NewNetwork()
+		a = NewCell(network)
+		b = NewCell(network)
+		c = NewCell(network)
+		d = NewCell(network)
+		ab = network.linkCells(a.ID, b.ID)
+		ab.Millivolts = 100
+		cd = network.linkCells(c.ID, d.ID)
+		cd.Millivolts = 100
+		vocab = NewVocabulary(network)
+		vocab.Outputs["x"] = &OutputCollection{Value: "x", FirePattern: FiringPattern{a.ID: 1}}
+	}
+	opts := func() SleepOptions {
+		return SleepOptions{
+			Vocab:           vocab,
+			Replays:         1,
+			ReinforceAmount: 10,
+			DownscaleRatio:  0.5,
+			PruneFloor:      20,
+		}
+	}
+
+	t.Run("reinforces the replayed synapses and downscales the others", func(t *testing.T) {
+		before()
+		report := network.Sleep(opts())
+		assert.Equal(t, int16(110), ab.Millivolts)
+		assert.Equal(t, int16(50), cd.Millivolts)
+		assert.Equal(t, SleepReport{Replayed: 1, Downscaled: 1, Pruned: 0}, report)
+	})
+	t.Run("replays without noise or learning, and restores them after", func(t *testing.T) {
+		before()
+		network.Laws.NoiseRatio = 1 // every cell would fire as noise
+		network.SetPlasticityRule(NewSTDPRule())
+		rule := network.plasticity
+		report := network.Sleep(opts())
+		assert.Equal(t, int16(110), ab.Millivolts)
+		assert.Equal(t, int16(50), cd.Millivolts)
+		assert.Equal(t, SleepReport{Replayed: 1, Downscaled: 1, Pruned: 0}, report)
+		assert.Equal(t, 1.0, network.Laws.NoiseRatio)
+		assert.Equal(t, "stdp", network.Laws.PlasticityRule)
+		assert.True(t, rule == network.plasticityRule())
+	})
+	t.Run("replays without synapse decay or threshold adaptation, and restores them after", func(t *testing.T) {
+		before()
+		network.Laws.SynapseDecayInterval = 1
+		network.Laws.SynapseDecayRatio = 0.5 // the idle synapse would halve every step
+		network.Laws.ThresholdAdaptation = 1000
+		synapses := len(network.Synapses)
+		report := network.Sleep(opts())
+		assert.Equal(t, synapses, len(network.Synapses))
+		assert.True(t, network.SynExists(cd.ID))
+		assert.Equal(t, int16(110), ab.Millivolts)
+		assert.Equal(t, int16(50), cd.Millivolts)
+		assert.Equal(t, SleepReport{Replayed: 1, Downscaled: 1, Pruned: 0}, report)
+		assert.Equal(t, 1, network.Laws.SynapseDecayInterval)
+		assert.Equal(t, 1000, network.Laws.ThresholdAdaptation)
+	})
+	t.Run("prunes the synapses that fall below the floor", func(t *testing.T) {
+		before()
+		cd.Millivolts = 30
+		report := network.Sleep(opts())
+		assert.False(t, network.SynExists(cd.ID))
+		assert.True(t, network.SynExists(ab.ID))
+		assert.Equal(t, 1, report.Pruned)
+		ok, integrity := CheckIntegrity(network)
+		assert.True(t, ok, integrity)
+	})
+	t.Run("leaves the network resting and as enabled as it was", func(t *testing.T) {
+		before()
+		network.Disabled = true
+		network.Sleep(opts())
+		assert.True(t, network.Disabled)
+		assert.Empty(t, network.nextSynapses)
+		assert.Empty(t, network.activeCells)
+	})
+	t.Run("merging a clone from before the sleep does not bring back pruned cells", func(t *testing.T) {
+		before()
+		clone := CloneNetwork(network)
+		cd.Millivolts = 30
+		network.Sleep(opts())
+		assert.False(t, network.CellExists(c.ID))
+
+		diff := DiffNetworks(network, clone)
+		ApplyDiff(diff, network)
+		assert.False(t, network.CellExists(c.ID))
+		ok, integrity := CheckIntegrity(network)
+		assert.True(t, ok, integrity)
+	})
+}
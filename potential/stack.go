@@ -0,0 +1,251 @@
+package potential
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+Stack is a network of networks. Each layer is a trained network and its vocab,
+and the cells that fire in one layer fire the input cells of the layer above
+it, through a Bridge. Only the first layer's inputs and the last layer's outputs
+are used when sampling; the layers in between pass firing patterns along.
+
+See docs/network-of-networks.md.
+*/
+type Stack struct {
+	Layers []*Layer
+}
+
+/*
+Layer is one network and vocab in a Stack.
+*/
+type Layer struct {
+	Name string
+	/*
+		NetworkFile and VocabFile are where the layer is saved, relative to the
+		stack's manifest. They default to the layer's name.
+	*/
+	NetworkFile string
+	VocabFile   string
+	/*
+		Bridge carries the firing pattern of the layer below into this one. The
+		first layer has none.
+	*/
+	Bridge *Bridge     `json:",omitempty"`
+	Vocab  *Vocabulary `json:"-"`
+}
+
+/*
+BridgeLink fires the To cell in the upper layer every time the From cell fires
+in the lower layer.
+*/
+type BridgeLink struct {
+	From CellID
+	To   CellID
+}
+
+/*
+Bridge maps cells in one layer's network to cells in the next layer's network.
+*/
+type Bridge struct {
+	Links []BridgeLink
+}
+
+/*
+NewStack is a Stack factory.
+*/
+func NewStack() *Stack {
+	return &Stack{Layers: make([]*Layer, 0)}
+}
+
+/*
+AddLayer puts a trained vocab and its network on top of the stack. The bridge
+links the layer below to this one, and must be nil for the first layer.
+*/
+func (stack *Stack) AddLayer(name string, vocab *Vocabulary, bridge *Bridge) *Layer {
+	layer := &Layer{Name: name, Vocab: vocab, Bridge: bridge}
+	stack.Layers = append(stack.Layers, layer)
+	return layer
+}
+
+/*
+BridgeOutputsToInputs links every output of the lower vocab to the input of the
+upper vocab with the same value, cell for cell. For example, a character network
+whose outputs are characters can feed a word network whose inputs are
+characters.
+*/
+func BridgeOutputsToInputs(lower, upper *Vocabulary) *Bridge {
+	bridge := &Bridge{Links: make([]BridgeLink, 0)}
+	for _, output := range lower.sortedOutputs() {
+		unit, ok := upper.Inputs[InputValue(output.Value)]
+		if !ok {
+			continue
+		}
+		for _, from := range sortedPatternCells(output.FirePattern) {
+			for _, to := range sortedPatternCells(unit.InputCells) {
+				bridge.Links = append(bridge.Links, BridgeLink{From: from, To: to})
+			}
+		}
+	}
+	return bridge
+}
+
+// sortedPatternCells returns the cells in a firing pattern in order
+func sortedPatternCells(fp FiringPattern) []CellID {
+	cellIDs := make([]CellID, 0, len(fp))
+	for cellID := range fp {
+		cellIDs = append(cellIDs, cellID)
+	}
+	sort.Slice(cellIDs, func(i, j int) bool { return cellIDs[i] < cellIDs[j] })
+	return cellIDs
+}
+
+/*
+Carry turns the firing pattern of the lower layer into the cells to fire in the
+upper layer. An upper cell is in it once when any lower cell linked to it fired.
+FireNetworkUntilDone only looks at which cells are in a pattern, so how many
+times they fired does not carry up.
+*/
+func (bridge *Bridge) Carry(fp FiringPattern) FiringPattern {
+	carried := make(FiringPattern)
+	for _, link := range bridge.Links {
+		if _, ok := fp[link.From]; ok {
+			carried[link.To] = 1
+		}
+	}
+	return carried
+}
+
+/*
+Validate checks that every layer has a vocab and network, and that every bridge
+links cells that exist on both sides.
+*/
+func (stack *Stack) Validate() error {
+	if len(stack.Layers) == 0 {
+		return errors.New("Stack has no layers")
+	}
+	for i, layer := range stack.Layers {
+		if layer.Vocab == nil || layer.Vocab.Net == nil {
+			return fmt.Errorf("Stack layer %s has no vocab or network", layer.Name)
+		}
+		if i == 0 {
+			if layer.Bridge != nil {
+				return fmt.Errorf("Stack layer %s is the first, so it cannot have a bridge", layer.Name)
+			}
+			continue
+		}
+		if layer.Bridge == nil {
+			return fmt.Errorf("Stack layer %s has no bridge from the layer below", layer.Name)
+		}
+		below := stack.Layers[i-1].Vocab.Net
+		for _, link := range layer.Bridge.Links {
+			if !below.CellExists(link.From) {
+				return fmt.Errorf("Bridge to stack layer %s is from cell %d, which is not in the layer below", layer.Name, link.From)
+			}
+			if !layer.Vocab.Net.CellExists(link.To) {
+				return fmt.Errorf("Bridge to stack layer %s is to cell %d, which is not in the layer", layer.Name, link.To)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+Fire fires the inputs into the first layer, and carries what fires up through
+every layer. Returns the firing pattern of the last layer.
+*/
+func (stack *Stack) Fire(inputs []InputValue) FiringPattern {
+	var fp FiringPattern
+	for i, layer := range stack.Layers {
+		var seed FiringPattern
+		if i == 0 {
+			seed = GetInputPatternForInputs(layer.Vocab, inputs)
+		} else {
+			seed = layer.Bridge.Carry(fp)
+		}
+		layer.Vocab.Net.ResetForTraining()
+		fp = FireNetworkUntilDone(layer.Vocab.Net, seed)
+	}
+	return fp
+}
+
+/*
+Sample is like the Sample function, but from the first layer's inputs to the
+last layer's outputs. The last layer's Readout picks the output when it has one.
+*/
+func (stack *Stack) Sample(seedText string) (output string) {
+	characters := strings.Split(seedText, "")
+	inputs := make([]InputValue, len(characters))
+	for i, char := range characters {
+		inputs[i] = InputValue(char)
+	}
+
+	finalPattern := stack.Fire(inputs)
+	top := stack.Layers[len(stack.Layers)-1]
+	return decodeOutput(finalPattern, top.Vocab)
+}
+
+/*
+SaveToFile saves the stack's manifest, a JSON description of its layers and
+bridges, and saves each layer's network and vocab next to it.
+*/
+func (stack *Stack) SaveToFile(manifestFile string) error {
+	if err := stack.Validate(); err != nil {
+		return err
+	}
+	dir := filepath.Dir(manifestFile)
+	for _, layer := range stack.Layers {
+		if layer.NetworkFile == "" {
+			layer.NetworkFile = layer.Name + ".nur"
+		}
+		if layer.VocabFile == "" {
+			layer.VocabFile = layer.Name + ".vocab.json"
+		}
+		if err := layer.Vocab.Net.SaveToFile(filepath.Join(dir, layer.NetworkFile)); err != nil {
+			return err
+		}
+		if err := layer.Vocab.SaveToFile(filepath.Join(dir, layer.VocabFile)); err != nil {
+			return err
+		}
+	}
+	contents, err := json.MarshalIndent(stack, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(manifestFile, contents, os.ModePerm)
+}
+
+/*
+LoadStackFromFile loads a stack's manifest, and every layer's network and vocab
+from the files it names.
+*/
+func LoadStackFromFile(manifestFile string) (*Stack, error) {
+	contents, err := ioutil.ReadFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	stack := NewStack()
+	if err = json.Unmarshal(contents, stack); err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(manifestFile)
+	for _, layer := range stack.Layers {
+		vocab, err := LoadVocabFromFile(filepath.Join(dir, layer.VocabFile))
+		if err != nil {
+			return nil, err
+		}
+		vocab.Net, err = LoadNetworkFromFile(filepath.Join(dir, layer.NetworkFile))
+		if err != nil {
+			return nil, err
+		}
+		layer.Vocab = vocab
+	}
+	return stack, stack.Validate()
+}
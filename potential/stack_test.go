@@ -0,0 +1,91 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Stack(t *testing.T) {
+	// chars: q -> (a -> b) -> x
+	// words: x -> (c -> d) -> y, and z -> (e -> f) -> w
+	var chars, words *Vocabulary
+	var a, b, c, d, e, f *Cell
+	before := func() {
+		charNet := NewNetwork()
+		charNet.Laws.NoiseRatio = 0
+		a = NewCell(charNet)
+		b = NewCell(charNet)
+		charNet.linkCells(a.ID, b.ID).Millivolts = charNet.Laws.ActualSynapseMax()
+		chars = NewVocabulary(charNet)
+		chars.Inputs["q"] = &VocabUnit{Value: "q", InputCells: FiringPattern{a.ID: 1}}
+		chars.Outputs["x"] = &OutputCollection{Value: "x", FirePattern: FiringPattern{b.ID: 1}}
+
+		wordNet := NewNetwork()
+		wordNet.Laws.NoiseRatio = 0
+		c = NewCell(wordNet)
+		d = NewCell(wordNet)
+		e = NewCell(wordNet)
+		f = NewCell(wordNet)
+		wordNet.linkCells(c.ID, d.ID).Millivolts = wordNet.Laws.ActualSynapseMax()
+		wordNet.linkCells(e.ID, f.ID).Millivolts = wordNet.Laws.ActualSynapseMax()
+		words = NewVocabulary(wordNet)
+		words.Inputs["x"] = &VocabUnit{Value: "x", InputCells: FiringPattern{c.ID: 1}}
+		words.Inputs["z"] = &VocabUnit{Value: "z", InputCells: FiringPattern{e.ID: 1}}
+		words.Outputs["y"] = &OutputCollection{Value: "y", FirePattern: FiringPattern{d.ID: 1}}
+		words.Outputs["w"] = &OutputCollection{Value: "w", FirePattern: FiringPattern{f.ID: 1}}
+	}
+
+	t.Run("bridges outputs to the inputs with the same value", func(t *testing.T) {
+		before()
+		bridge := BridgeOutputsToInputs(chars, words)
+		assert.Equal(t, []BridgeLink{{From: b.ID, To: c.ID}}, bridge.Links)
+		assert.Equal(t, FiringPattern{c.ID: 1}, bridge.Carry(FiringPattern{a.ID: 2, b.ID: 3}))
+		assert.Equal(t, FiringPattern{}, bridge.Carry(FiringPattern{a.ID: 2}))
+	})
+	t.Run("samples from the bottom layer's inputs to the top layer's outputs", func(t *testing.T) {
+		before()
+		stack := NewStack()
+		stack.AddLayer("chars", chars, nil)
+		stack.AddLayer("words", words, BridgeOutputsToInputs(chars, words))
+		assert.NoError(t, stack.Validate())
+		assert.Equal(t, "y", stack.Sample("q"))
+	})
+	t.Run("the top layer's readout picks the output", func(t *testing.T) {
+		before()
+		stack := NewStack()
+		stack.AddLayer("chars", chars, nil)
+		stack.AddLayer("words", words, BridgeOutputsToInputs(chars, words))
+		// backwards from the output patterns, so only the readout gets it right
+		err := words.AddTrainingData([]byte(`[{"InputText":"x","ExpectedOutput":"w"},{"InputText":"z","ExpectedOutput":"y"}]`))
+		assert.NoError(t, err)
+		readout, _, err := TrainReadout(words, DefaultReadoutOptions())
+		assert.NoError(t, err)
+		words.Readout = readout
+		assert.Equal(t, "w", stack.Sample("q"))
+	})
+	t.Run("saves a manifest and loads the layers back from it", func(t *testing.T) {
+		before()
+		stack := NewStack()
+		stack.AddLayer("_stack_chars.test", chars, nil)
+		stack.AddLayer("_stack_words.test", words, BridgeOutputsToInputs(chars, words))
+		assert.NoError(t, stack.SaveToFile("_stack.test.json"))
+
+		loaded, err := LoadStackFromFile("_stack.test.json")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(loaded.Layers))
+		assert.Equal(t, "_stack_words.test.nur", loaded.Layers[1].NetworkFile)
+		assert.Equal(t, stack.Layers[1].Bridge, loaded.Layers[1].Bridge)
+		assert.Equal(t, "y", loaded.Sample("q"))
+	})
+	t.Run("is invalid when a bridge links cells that do not exist", func(t *testing.T) {
+		before()
+		stack := NewStack()
+		stack.AddLayer("chars", chars, nil)
+		stack.AddLayer("words", words, &Bridge{Links: []BridgeLink{{From: b.ID, To: 99}}})
+		assert.Error(t, stack.Validate())
+
+		stack.Layers[1].Bridge = nil
+		assert.Error(t, stack.Validate())
+	})
+}
@@ -0,0 +1,245 @@
+package potential
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+/*
+CellState is the part of a cell that changes from step to step.
+*/
+type CellState struct {
+	Voltage        int16
+	Activating     bool  `json:",omitempty"`
+	RefractoryLeft uint8 `json:",omitempty"`
+	Threshold      int   `json:",omitempty"`
+}
+
+/*
+NetworkState is a snapshot of a network in the middle of firing: cell voltages,
+refractory periods, synapses waiting to deliver, the step clock, the plasticity
+rule's memory and the random number generator.
+
+The network file only holds the structure, and loading it starts the network at
+rest. Capture a state to pause a network mid-sequence, save it next to the
+network, and restore it later to carry on exactly where it left off - even in
+another process.
+
+A state only fits the network it came from, and only until cells or synapses
+are pruned or compacted.
+*/
+type NetworkState struct {
+	Steps           int
+	StepsSinceDecay int
+	// Seed reseeds the network's random number generator on restore.
+	Seed int64
+	// Cells holds only the cells that were not at rest.
+	Cells map[CellID]CellState
+	// NextSynapses deliver on the next step, in order.
+	NextSynapses []SynapseID
+	// Pending are the delayed synapses. Index zero delivers on the next step,
+	// index one the step after that, and so on.
+	Pending [][]SynapseID `json:",omitempty"`
+	// Delivered are the synapses that delivered since they last decayed.
+	Delivered []SynapseID `json:",omitempty"`
+	LastFired []CellID    `json:",omitempty"`
+	// Plasticity is the rule's state, if it is a StatefulPlasticityRule.
+	Plasticity json.RawMessage `json:",omitempty"`
+}
+
+/*
+StatefulPlasticityRule is a PlasticityRule that remembers things between steps,
+which should be kept in a NetworkState.
+*/
+type StatefulPlasticityRule interface {
+	PlasticityRule
+	MarshalState() ([]byte, error)
+	UnmarshalState(data []byte) error
+}
+
+/*
+CaptureState takes a snapshot of everything about the network that is not saved
+in the network file.
+
+The random number generator cannot be copied, so it is reseeded from itself and
+the new seed goes in the state. The network fires the same afterwards as a
+network restored from the state.
+*/
+func (network *Network) CaptureState() (*NetworkState, error) {
+	l := network.Laws
+	state := &NetworkState{
+		Steps:           network.steps,
+		StepsSinceDecay: network.stepsSinceDecay,
+		Cells:           make(map[CellID]CellState),
+		NextSynapses:    append([]SynapseID(nil), network.nextSynapses...),
+		LastFired:       append([]CellID(nil), network.lastFired...),
+	}
+
+	for _, cell := range network.Cells {
+		if cell == nil { // pruned
+			continue
+		}
+		if cell.Voltage == l.CellRestingVoltage && !cell.activating && cell.refractoryLeft == 0 && !cell.isAdapted() {
+			continue
+		}
+		state.Cells[cell.ID] = CellState{
+			Voltage:        cell.Voltage,
+			Activating:     cell.activating,
+			RefractoryLeft: cell.refractoryLeft,
+			Threshold:      cell.Threshold,
+		}
+	}
+	for _, synapse := range network.Synapses {
+		if synapse != nil && synapse.delivered {
+			state.Delivered = append(state.Delivered, synapse.ID)
+		}
+	}
+	if network.totalPending > 0 {
+		state.Pending = make([][]SynapseID, deliveryRingSize)
+		last := 0
+		for i := range state.Pending {
+			due := network.pendingDeliveries[(network.deliveryCursor+i)%deliveryRingSize]
+			if len(due) > 0 {
+				state.Pending[i] = append([]SynapseID(nil), due...)
+				last = i
+			}
+		}
+		state.Pending = state.Pending[:last+1]
+	}
+
+	if rule, ok := network.plasticityRule().(StatefulPlasticityRule); ok {
+		data, err := rule.MarshalState()
+		if err != nil {
+			return nil, err
+		}
+		state.Plasticity = data
+	}
+
+	state.Seed = network.rng.Int63()
+	network.SetSeed(state.Seed)
+	return state, nil
+}
+
+/*
+RestoreState puts the network back the way it was when the state was captured.
+Anything the network was doing beforehand is forgotten. Returns an error, and
+leaves the network alone, if the state refers to cells or synapses the network
+does not have, or the plasticity rule cannot read its part of the state.
+*/
+func (network *Network) RestoreState(state *NetworkState) error {
+	rule, err := network.checkState(state)
+	if err != nil {
+		return err
+	}
+
+	for _, cell := range network.Cells {
+		if cell == nil { // pruned
+			continue
+		}
+		cell.postRefractoryReset()
+		cell.Threshold = 0
+	}
+	for _, synapse := range network.Synapses {
+		if synapse == nil { // pruned
+			continue
+		}
+		synapse.fireNextRound = false
+		synapse.delivered = false
+	}
+	network.clearPendingDeliveries()
+	network.nextSynapses = nil
+	network.activeCells = make(map[CellID]bool)
+
+	for cellID, cellState := range state.Cells {
+		cell := network.GetCell(cellID)
+		cell.Voltage = cellState.Voltage
+		cell.activating = cellState.Activating
+		cell.refractoryLeft = cellState.RefractoryLeft
+		cell.Threshold = cellState.Threshold
+		network.markActive(cell)
+	}
+	for _, synapseID := range state.NextSynapses {
+		network.queueSynapse(network.GetSyn(synapseID))
+	}
+	for delay, due := range state.Pending {
+		for _, synapseID := range due {
+			network.scheduleDelivery(synapseID, uint8(delay))
+		}
+	}
+	for _, synapseID := range state.Delivered {
+		network.GetSyn(synapseID).delivered = true
+	}
+	network.lastFired = append([]CellID(nil), state.LastFired...)
+	network.steps = state.Steps
+	network.stepsSinceDecay = state.StepsSinceDecay
+
+	network.plasticity = rule
+	network.SetSeed(state.Seed)
+	return nil
+}
+
+/*
+checkState makes sure everything in the state is still in the network, and
+reads the plasticity rule's state into a new rule, without changing the network.
+*/
+func (network *Network) checkState(state *NetworkState) (PlasticityRule, error) {
+	if len(state.Pending) > deliveryRingSize {
+		return nil, fmt.Errorf("State has synapses pending for %d steps, more than any delay", len(state.Pending))
+	}
+	for cellID := range state.Cells {
+		if !network.CellExists(cellID) {
+			return nil, fmt.Errorf("State has cell %d which is not in the network", cellID)
+		}
+	}
+	for _, cellID := range state.LastFired {
+		if !network.CellExists(cellID) {
+			return nil, fmt.Errorf("State has fired cell %d which is not in the network", cellID)
+		}
+	}
+	synapseIDs := append(append([]SynapseID(nil), state.NextSynapses...), state.Delivered...)
+	for _, due := range state.Pending {
+		synapseIDs = append(synapseIDs, due...)
+	}
+	for _, synapseID := range synapseIDs {
+		if !network.SynExists(synapseID) {
+			return nil, fmt.Errorf("State has synapse %d which is not in the network", synapseID)
+		}
+	}
+
+	rule, err := NewPlasticityRule(network.Laws.PlasticityRule)
+	if err != nil {
+		return nil, err
+	}
+	if stateful, ok := rule.(StatefulPlasticityRule); ok && len(state.Plasticity) > 0 {
+		if err = stateful.UnmarshalState(state.Plasticity); err != nil {
+			return nil, err
+		}
+	}
+	return rule, nil
+}
+
+/*
+SaveToFile writes the state to a JSON file.
+*/
+func (state *NetworkState) SaveToFile(filepath string) error {
+	contents, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath, contents, os.ModePerm)
+}
+
+/*
+LoadNetworkStateFromFile reads a state written by `NetworkState.SaveToFile`.
+*/
+func LoadNetworkStateFromFile(filepath string) (*NetworkState, error) {
+	contents, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	state := &NetworkState{}
+	err = json.Unmarshal(contents, state)
+	return state, err
+}
@@ -0,0 +1,109 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NetworkState(t *testing.T) {
+	// a -> b -> c, where b -> c is delayed, and d is left charged
+	var network *Network
+	var a, b, c, d *Cell
+	before := func() {
+		network = NewNetwork()
+		network.SetSeed(5)
+		network.Laws.NoiseRatio = 0
+		a = NewCell(network)
+		b = NewCell(network)
+		c = NewCell(network)
+		d = NewCell(network)
+		network.linkCells(a.ID, b.ID).Millivolts = network.Laws.ActualSynapseMax()
+		bc := network.linkCells(b.ID, c.ID)
+		bc.Millivolts = network.Laws.ActualSynapseMax()
+		bc.Delay = 2
+	}
+	firedOn := func(network *Network, steps int) (fired [][]CellID) {
+		for i := 0; i < steps; i++ {
+			network.Step()
+			fired = append(fired, network.FiredLastStep())
+		}
+		return fired
+	}
+
+	t.Run("a restored network carries on where the captured one left off", func(t *testing.T) {
+		before()
+		a.FireActionPotential()
+		network.Step()
+		network.GetCell(d.ID).Voltage = 30
+		network.markActive(d)
+
+		state, err := network.CaptureState()
+		assert.NoError(t, err)
+		assert.NoError(t, network.SaveToFile("_network_state.test.nur"))
+		assert.NoError(t, state.SaveToFile("_network_state.test.json"))
+
+		net2, err := LoadNetworkFromFile("_network_state.test.nur")
+		assert.NoError(t, err)
+		state2, err := LoadNetworkStateFromFile("_network_state.test.json")
+		assert.NoError(t, err)
+		assert.NoError(t, net2.RestoreState(state2))
+
+		assert.Equal(t, network.Steps(), net2.Steps())
+		assert.Equal(t, network.GetCell(d.ID).Voltage, net2.GetCell(d.ID).Voltage)
+		assert.Equal(t, network.FiredLastStep(), net2.FiredLastStep())
+		expected := firedOn(network, 4)
+		assert.Contains(t, expected[2], c.ID, "the delayed synapse is still on its way")
+		assert.Equal(t, expected, firedOn(net2, 4))
+		assert.Equal(t, network.RandomCellKey(), net2.RandomCellKey())
+	})
+	t.Run("only keeps the cells that are not at rest", func(t *testing.T) {
+		before()
+		a.FireActionPotential()
+		network.Step()
+		state, err := network.CaptureState()
+		assert.NoError(t, err)
+		assert.Contains(t, state.Cells, b.ID)
+		assert.NotContains(t, state.Cells, d.ID)
+	})
+	t.Run("keeps the spike times of the stdp rule", func(t *testing.T) {
+		before()
+		network.Laws.PlasticityRule = "stdp"
+		a.FireActionPotential()
+		network.Step()
+		state, err := network.CaptureState()
+		assert.NoError(t, err)
+
+		clone := CloneNetwork(network)
+		clone.ResetForTraining()
+		assert.NoError(t, clone.RestoreState(state))
+		assert.Equal(t, network.plasticityRule().(*STDPRule).lastSpike, clone.plasticityRule().(*STDPRule).lastSpike)
+	})
+	t.Run("will not restore onto a network without the same cells", func(t *testing.T) {
+		before()
+		b.FireActionPotential()
+		state, err := network.CaptureState()
+		assert.NoError(t, err)
+		other := NewNetwork()
+		NewCell(other)
+		assert.Error(t, other.RestoreState(state))
+	})
+	t.Run("leaves the network alone when the rule cannot read its state", func(t *testing.T) {
+		before()
+		network.Laws.PlasticityRule = "stdp"
+		a.FireActionPotential()
+		network.Step()
+		state, err := network.CaptureState()
+		assert.NoError(t, err)
+		state.Plasticity = []byte(`"not the stdp state"`)
+
+		a.FireActionPotential()
+		network.Step()
+		voltage, steps := network.GetCell(b.ID).Voltage, network.Steps()
+		rule := network.plasticityRule()
+		assert.Error(t, network.RestoreState(state))
+		assert.Equal(t, voltage, network.GetCell(b.ID).Voltage)
+		assert.Equal(t, steps, network.Steps())
+		assert.True(t, rule == network.plasticityRule())
+	})
+}
@@ -2,8 +2,7 @@ package potential
 
 import (
 	"fmt"
-
-	"github.com/ruffrey/nurtrace/laws"
+	"math"
 )
 
 // Synapses that fire together wire together.
@@ -25,11 +24,20 @@ type Synapse struct {
 	Network *Network `json:"-"` // skip circular reference in JSON
 	// Represents how many millivolts a synapse can modify the cell's
 	// voltage which receives its firings.
-	Millivolts        int16
-	FromNeuronAxon    CellID
-	ToNeuronDendrite  CellID
-	ActivationHistory uint `json:"-"` // unnecessary to recreate synapse
-	fireNextRound     bool
+	Millivolts       int16
+	FromNeuronAxon   CellID
+	ToNeuronDendrite CellID
+	// ActivationHistory is how many times the synapse delivered voltage. It is
+	// saved, so silent synapses can be pruned across training sessions.
+	ActivationHistory uint
+	/*
+		Delay is how many extra steps it takes for this synapse to apply its
+		voltage after its axon cell fires. Zero means the very next step.
+	*/
+	Delay         uint8
+	fireNextRound bool
+	// delivered is whether the synapse applied voltage since the last decay
+	delivered bool
 }
 
 /*
@@ -38,7 +46,7 @@ NewSynapse instantiates a synapse with a random millivolt weight.
 It is up to the implementer to set add it to the network and set the pointer.
 */
 func NewSynapse(network *Network) *Synapse {
-	mv := int16(randomIntBetween(laws.NewSynapseMinMillivolts, laws.NewSynapseMaxMillivolts))
+	mv := int16(network.randomIntBetween(network.Laws.NewSynapseMinMillivolts, network.Laws.NewSynapseMaxMillivolts))
 
 	network.synMux.Lock()
 	s := Synapse{
@@ -58,47 +66,134 @@ reinforce a synapse relationship and create a new synapse of the same
 direction if so.
 */
 func (synapse *Synapse) reinforce() SynapseID {
-	return reinforceByAmount(synapse, laws.SynapseLearnRate)
+	return reinforceByAmount(synapse, synapse.Network.Laws.SynapseLearnRate)
 }
 
 func reinforceByAmount(synapse *Synapse, millivolts int16) (newSynapse SynapseID) {
+	defer func() {
+		for _, observer := range synapse.Network.observers {
+			observer.SynapseReinforced(synapse.Network, synapse.ID)
+		}
+	}()
 	mv := int16(millivolts)
+	actualSynapseMax := synapse.Network.Laws.ActualSynapseMax()
+	actualSynapseMin := synapse.Network.Laws.ActualSynapseMin()
 	isPositive := synapse.Millivolts >= 0
+	// the math is done in int, so amounts bigger than the learn rate cannot overflow
 	if isPositive {
-		newMV := int16(synapse.Millivolts) + mv
-		if newMV > laws.ActualSynapseMax {
-			half := laws.ActualSynapseMax / 2
+		newMV := int(synapse.Millivolts) + int(mv)
+		if newMV > int(actualSynapseMax) {
+			half := actualSynapseMax / 2
 			synapse.Millivolts = half
 			// add a new synapse between those two cells
 			s := synapse.Network.linkCells(synapse.FromNeuronAxon, synapse.ToNeuronDendrite)
 			newSynapse = s.ID
 			s.Millivolts = half
+			s.Delay = synapse.Delay
 		} else {
-			synapse.Millivolts = newMV
+			synapse.Millivolts = int16(newMV)
 		}
 		return newSynapse
 	}
 	// negative
-	newMV := int16(synapse.Millivolts) - mv
-	if newMV < laws.ActualSynapseMin {
-		half := laws.ActualSynapseMin / 2
+	newMV := int(synapse.Millivolts) - int(mv)
+	if newMV < int(actualSynapseMin) {
+		half := actualSynapseMin / 2
 		synapse.Millivolts = half
 		// add a new synapse between those two cells
 		s := synapse.Network.linkCells(synapse.FromNeuronAxon, synapse.ToNeuronDendrite)
 		newSynapse = s.ID
 		s.Millivolts = half
+		s.Delay = synapse.Delay
 	} else {
-		synapse.Millivolts = newMV
+		synapse.Millivolts = int16(newMV)
 	}
 	return newSynapse
 }
 
+/*
+weaken moves a synapse toward zero when it delivered voltage to a cell that
+did not fire. Returns true if the synapse was pruned.
+*/
+func (synapse *Synapse) weaken() (pruned bool) {
+	return weakenByRatio(synapse, synapse.Network.Laws.SynapseWeakenRatio)
+}
+
+/*
+weakenByRatio moves a synapse toward zero by a ratio of its distance to zero.
+Once it is within the learn rate of zero it becomes zero, and a synapse at zero
+does nothing, so it gets pruned.
+
+Returns true if the synapse was pruned.
+*/
+func weakenByRatio(synapse *Synapse, ratio float64) (pruned bool) {
+	if ratio <= 0 {
+		return false
+	}
+	mv := float64(synapse.Millivolts)
+	newMV := int16(mv - mv*ratio) // truncates toward zero
+	return setWeakened(synapse, newMV)
+}
+
+/*
+weakenByAmount moves a synapse toward zero by some millivolts, without crossing
+zero. Returns true if the synapse was pruned.
+*/
+func weakenByAmount(synapse *Synapse, millivolts int16) (pruned bool) {
+	if millivolts <= 0 {
+		return false
+	}
+	newMV := int(synapse.Millivolts)
+	if newMV > 0 {
+		newMV = int(math.Max(float64(newMV-int(millivolts)), 0))
+	} else {
+		newMV = int(math.Min(float64(newMV+int(millivolts)), 0))
+	}
+	return setWeakened(synapse, int16(newMV))
+}
+
+// setWeakened makes a synapse within the learn rate of zero become zero, and
+// prunes it if it is zero.
+func setWeakened(synapse *Synapse, newMV int16) (pruned bool) {
+	learnRate := synapse.Network.Laws.SynapseLearnRate
+	if newMV <= learnRate && newMV >= -learnRate {
+		newMV = 0
+	}
+	synapse.Millivolts = newMV
+	if newMV != 0 {
+		return false
+	}
+	synapse.Network.PruneSynapse(synapse.ID)
+	return true
+}
+
+/*
+DecaySynapses moves every synapse that has not delivered voltage since the last
+decay toward zero by the `SynapseDecayRatio` law, pruning the ones that reach
+zero. Step calls this every `SynapseDecayInterval` steps, but it can be called
+any time.
+*/
+func (network *Network) DecaySynapses() {
+	ratio := network.Laws.SynapseDecayRatio
+	for _, synapse := range network.Synapses {
+		if synapse == nil { // pruned
+			continue
+		}
+		if synapse.delivered {
+			synapse.delivered = false
+			continue
+		}
+		weakenByRatio(synapse, ratio)
+	}
+}
+
 func (synapse *Synapse) String() string {
 	s := fmt.Sprintf("Synapse %d", synapse.ID)
 	s += fmt.Sprintf("\n  Millivolts=%d", synapse.Millivolts)
 	s += fmt.Sprintf("\n  ActivationHistory=%d", synapse.ActivationHistory)
 	s += fmt.Sprintf("\n  FromNeuronAxon=%d", synapse.FromNeuronAxon)
 	s += fmt.Sprintf("\n  ToNeuronDendrite=%d", synapse.ToNeuronDendrite)
+	s += fmt.Sprintf("\n  Delay=%d", synapse.Delay)
 
 	return s
 }
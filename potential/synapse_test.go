@@ -70,6 +70,75 @@ func Test_SynapseReinforce(t *testing.T) {
 	})
 }
 
+func Test_SynapseWeaken(t *testing.T) {
+	var network *Network
+	var synapse *Synapse
+	before := func(mv int16) {
+		network = NewNetwork()
+		network.Laws.SynapseWeakenRatio = 0.5
+		synapse = network.linkCells(NewCell(network).ID, NewCell(network).ID)
+		synapse.Millivolts = mv
+	}
+
+	t.Run("moves positive and negative synapses toward zero by the ratio", func(t *testing.T) {
+		before(300)
+		assert.False(t, synapse.weaken())
+		assert.Equal(t, int16(150), synapse.Millivolts)
+
+		before(-300)
+		assert.False(t, synapse.weaken())
+		assert.Equal(t, int16(-150), synapse.Millivolts)
+	})
+	t.Run("does nothing when the ratio is zero", func(t *testing.T) {
+		before(300)
+		network.Laws.SynapseWeakenRatio = 0
+		assert.False(t, synapse.weaken())
+		assert.Equal(t, int16(300), synapse.Millivolts)
+	})
+	t.Run("becomes zero within the learn rate, and is pruned", func(t *testing.T) {
+		before(5)
+		assert.True(t, synapse.weaken())
+		assert.Equal(t, int16(0), synapse.Millivolts)
+		assert.False(t, network.SynExists(synapse.ID))
+		ok, report := CheckIntegrity(network)
+		assert.True(t, ok, report)
+	})
+}
+
+func Test_DecaySynapses(t *testing.T) {
+	t.Run("only idle synapses decay", func(t *testing.T) {
+		network := NewNetwork()
+		network.Laws.SynapseDecayRatio = 0.1
+		idle := network.linkCells(NewCell(network).ID, NewCell(network).ID)
+		idle.Millivolts = 1000
+		busy := network.linkCells(NewCell(network).ID, NewCell(network).ID)
+		busy.Millivolts = 1000
+		busy.delivered = true
+
+		network.DecaySynapses()
+		assert.Equal(t, int16(900), idle.Millivolts)
+		assert.Equal(t, int16(1000), busy.Millivolts)
+
+		// busy is idle now, until it delivers again
+		network.DecaySynapses()
+		assert.Equal(t, int16(810), idle.Millivolts)
+		assert.Equal(t, int16(900), busy.Millivolts)
+	})
+	t.Run("decays idle synapses to zero and prunes them", func(t *testing.T) {
+		network := NewNetwork()
+		network.Laws.SynapseDecayRatio = 0.5
+		synapse := network.linkCells(NewCell(network).ID, NewCell(network).ID)
+		synapse.Millivolts = 16
+		for i := 0; i < 3; i++ {
+			network.DecaySynapses()
+		}
+		assert.False(t, network.SynExists(synapse.ID))
+		// both cells had nothing else
+		assert.Nil(t, network.Cells[0])
+		assert.Nil(t, network.Cells[1])
+	})
+}
+
 func Test_PruneSynapse(t *testing.T) {
 	var network *Network
 	var synapse *Synapse
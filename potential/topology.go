@@ -0,0 +1,327 @@
+package potential
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+/*
+Topologies are the names GrowTopology knows how to grow.
+
+- `random` - Grow: random synapses plus random deep paths
+- `small-world` - GrowSmallWorld, Watts-Strogatz
+- `scale-free` - GrowScaleFree, Barabási-Albert
+- `lattice` - GrowLattice, a square grid of local connections
+- `layered` - GrowLayered, feed-forward layers
+*/
+var Topologies = []string{"random", "small-world", "scale-free", "lattice", "layered"}
+
+/*
+TopologyOptions are the settings for GrowTopology. Zero values are defaults.
+*/
+type TopologyOptions struct {
+	// Cells is how many cells to grow. Lattices and layers round it to fit
+	// their shape.
+	Cells int
+	// Degree is how many synapses each cell sends, on average. Defaults to the
+	// `IdealCellSynapseBalance` law.
+	Degree int
+	// Rewire is the chance a small-world synapse goes to a random cell instead
+	// of a neighbor. Defaults to 0.1.
+	Rewire float64
+	// Radius is how many cells away a lattice cell connects. Defaults to 1.
+	Radius int
+	// Layers is how many layers a layered network has. Defaults to 4.
+	Layers int
+}
+
+/*
+GrowTopology grows the named topology onto the network, and returns the degree
+statistics of the whole network afterwards. The network's random number
+generator makes it repeatable.
+*/
+func (network *Network) GrowTopology(name string, opts TopologyOptions) (DegreeStats, error) {
+	if opts.Degree <= 0 {
+		opts.Degree = network.Laws.ComputedSynapsesPerCell()
+	}
+	if opts.Rewire <= 0 {
+		opts.Rewire = 0.1
+	}
+	if opts.Radius <= 0 {
+		opts.Radius = 1
+	}
+	if opts.Layers <= 0 {
+		opts.Layers = 4
+	}
+
+	switch name {
+	case "", "random":
+		network.Grow(opts.Cells, opts.Degree, 0)
+	case "small-world":
+		network.GrowSmallWorld(opts.Cells, opts.Degree, opts.Rewire)
+	case "scale-free":
+		network.GrowScaleFree(opts.Cells, opts.Degree)
+	case "lattice":
+		width := int(math.Ceil(math.Sqrt(float64(opts.Cells))))
+		height := int(math.Ceil(float64(opts.Cells) / float64(width)))
+		network.GrowLattice(width, height, opts.Radius)
+	case "layered":
+		network.GrowLayered(opts.Layers, opts.Cells/opts.Layers, opts.Degree)
+	default:
+		return DegreeStats{}, fmt.Errorf("Unknown topology %s, expected one of %v", name, Topologies)
+	}
+	return network.DegreeStats(), nil
+}
+
+/*
+growCells adds cells with no synapses, giving them types if Dale's law is on.
+*/
+func (network *Network) growCells(count int) []*Cell {
+	cells := make([]*Cell, count)
+	for i := range cells {
+		cells[i] = NewCell(network)
+	}
+	network.assignCellTypes(cells)
+	return cells
+}
+
+/*
+growSynapseOnce is growSynapse, unless the cells are the same or already
+linked that way. Returns whether it grew one.
+*/
+func (network *Network) growSynapseOnce(fromCellID CellID, toCellID CellID) bool {
+	if fromCellID == toCellID {
+		return false
+	}
+	for synapseID := range network.GetCell(fromCellID).AxonSynapses {
+		if network.GetSyn(synapseID).ToNeuronDendrite == toCellID {
+			return false
+		}
+	}
+	network.growSynapse(fromCellID, toCellID)
+	return true
+}
+
+/*
+GrowSmallWorld grows a Watts-Strogatz small-world network. The cells sit in a
+ring, each sending synapses to the `degree` cells nearest it, half on each
+side. Then each synapse goes to a random cell instead, by chance of `rewire`.
+A little rewiring keeps the neighbors clustered while making every cell only a
+few synapses from any other.
+*/
+func (network *Network) GrowSmallWorld(cells, degree int, rewire float64) {
+	ring := network.growCells(cells)
+	if cells < 2 {
+		return
+	}
+	half := degree / 2
+	if half < 1 {
+		half = 1
+	}
+	for i, cell := range ring {
+		for j := 1; j <= half; j++ {
+			for _, neighbor := range []int{(i + j) % cells, (i - j + cells) % cells} {
+				to := ring[neighbor].ID
+				if network.rng.Float64() < rewire {
+					to = ring[network.rng.Intn(cells)].ID
+				}
+				network.growSynapseOnce(cell.ID, to)
+			}
+		}
+	}
+}
+
+/*
+GrowScaleFree grows a Barabási-Albert scale-free network. Each new cell links
+to `degree` of the cells before it, picked by how many synapses they already
+have, so a few hub cells end up with many. Each link goes one way or the other
+at random, so hubs send as well as receive.
+*/
+func (network *Network) GrowScaleFree(cells, degree int) {
+	grown := network.growCells(cells)
+	if degree < 1 {
+		degree = 1
+	}
+	// every synapse puts both its cells in here, so picking from it picks
+	// cells by their degree
+	var ends []CellID
+	link := func(a, b CellID) {
+		from, to := a, b
+		if network.rng.Intn(2) == 0 {
+			from, to = b, a
+		}
+		if network.growSynapseOnce(from, to) {
+			ends = append(ends, from, to)
+		}
+	}
+
+	// the first few cells all link to each other
+	seed := degree + 1
+	if seed > len(grown) {
+		seed = len(grown)
+	}
+	for i := 0; i < seed; i++ {
+		for j := i + 1; j < seed; j++ {
+			link(grown[i].ID, grown[j].ID)
+		}
+	}
+	for _, cell := range grown[seed:] {
+		targets := make(map[CellID]bool)
+		for tries := 0; len(targets) < degree && tries < degree*10; tries++ {
+			targets[ends[network.rng.Intn(len(ends))]] = true
+		}
+		for _, target := range sortedCellIDs(targets) {
+			link(cell.ID, target)
+		}
+	}
+}
+
+/*
+GrowLattice grows a width by height grid of cells. Each cell sends synapses to
+every cell within `radius` rows and columns of it. The grid does not wrap
+around at the edges.
+*/
+func (network *Network) GrowLattice(width, height, radius int) {
+	grid := network.growCells(width * height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			from := grid[y*width+x].ID
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					network.growSynapseOnce(from, grid[ny*width+nx].ID)
+				}
+			}
+		}
+	}
+}
+
+/*
+GrowLayered grows feed-forward layers of cells. Each cell sends `degree`
+synapses to random cells in the next layer, and the last layer sends none. The
+layers are regions named `layer-0`, `layer-1` and so on.
+*/
+func (network *Network) GrowLayered(layers, cellsPerLayer, degree int) {
+	grown := make([][]*Cell, layers)
+	for l := range grown {
+		grown[l] = network.growCells(cellsPerLayer)
+		region := fmt.Sprintf("layer-%d", l)
+		network.AddRegion(region)
+		for _, cell := range grown[l] {
+			cell.Region = region
+		}
+	}
+	network.regionCells = nil
+	if degree > cellsPerLayer {
+		degree = cellsPerLayer
+	}
+	for l := 0; l < layers-1; l++ {
+		next := grown[l+1]
+		for _, cell := range grown[l] {
+			for _, ix := range network.rng.Perm(len(next))[:degree] {
+				network.growSynapseOnce(cell.ID, next[ix].ID)
+			}
+		}
+	}
+}
+
+/*
+DegreeStats describes how connected the cells of a network are.
+*/
+type DegreeStats struct {
+	Cells    int
+	Synapses int
+	// MeanDegree is the average number of synapses a cell sends, which is the
+	// same as the average it receives.
+	MeanDegree float64
+	MinIn      int
+	MaxIn      int
+	MinOut     int
+	MaxOut     int
+	// Clustering is the average of how linked each cell's neighbors are to
+	// each other, ignoring which way the synapses go. Zero to one.
+	Clustering float64
+}
+
+/*
+DegreeStats counts how many synapses go in and out of the cells of the network.
+*/
+func (network *Network) DegreeStats() DegreeStats {
+	stats := DegreeStats{MinIn: math.MaxInt32, MinOut: math.MaxInt32}
+	neighbors := make(map[CellID]map[CellID]bool)
+	for _, cell := range network.Cells {
+		if cell == nil { // pruned
+			continue
+		}
+		stats.Cells++
+		in, out := len(cell.DendriteSynapses), len(cell.AxonSynapses)
+		stats.MinIn = minInt(stats.MinIn, in)
+		stats.MaxIn = maxInt(stats.MaxIn, in)
+		stats.MinOut = minInt(stats.MinOut, out)
+		stats.MaxOut = maxInt(stats.MaxOut, out)
+		neighbors[cell.ID] = make(map[CellID]bool)
+	}
+	if stats.Cells == 0 {
+		return DegreeStats{}
+	}
+	for _, synapse := range network.Synapses {
+		if synapse == nil { // pruned
+			continue
+		}
+		stats.Synapses++
+		if synapse.FromNeuronAxon != synapse.ToNeuronDendrite {
+			neighbors[synapse.FromNeuronAxon][synapse.ToNeuronDendrite] = true
+			neighbors[synapse.ToNeuronDendrite][synapse.FromNeuronAxon] = true
+		}
+	}
+	stats.MeanDegree = float64(stats.Synapses) / float64(stats.Cells)
+
+	clustering := 0.0
+	for _, around := range neighbors {
+		if len(around) < 2 {
+			continue
+		}
+		cells := sortedCellIDs(around)
+		linked := 0
+		for i, a := range cells {
+			for _, b := range cells[i+1:] {
+				if neighbors[a][b] {
+					linked++
+				}
+			}
+		}
+		clustering += float64(linked) / float64(len(cells)*(len(cells)-1)/2)
+	}
+	stats.Clustering = clustering / float64(stats.Cells)
+	return stats
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+/*
+Print logs the degree statistics.
+*/
+func (stats DegreeStats) Print() {
+	log.Println("Degree statistics")
+	log.Println("  cells=", stats.Cells, "synapses=", stats.Synapses)
+	log.Println("  mean degree=", stats.MeanDegree)
+	log.Println("  in degree min=", stats.MinIn, "max=", stats.MaxIn)
+	log.Println("  out degree min=", stats.MinOut, "max=", stats.MaxOut)
+	log.Println("  clustering=", stats.Clustering)
+}
@@ -0,0 +1,73 @@
+package potential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Topology(t *testing.T) {
+	grow := func(name string, opts TopologyOptions) (*Network, DegreeStats) {
+		network := NewNetwork()
+		network.SetSeed(7)
+		stats, err := network.GrowTopology(name, opts)
+		assert.NoError(t, err)
+		ok, integrity := CheckIntegrity(network)
+		assert.True(t, ok, integrity)
+		return network, stats
+	}
+
+	t.Run("small-world without rewiring is a clustered ring", func(t *testing.T) {
+		_, stats := grow("small-world", TopologyOptions{Cells: 50, Degree: 4, Rewire: 0.0001})
+		assert.Equal(t, 50, stats.Cells)
+		assert.InDelta(t, 4, stats.MeanDegree, 0.1)
+		assert.True(t, stats.Clustering > 0.4, stats.Clustering)
+	})
+	t.Run("small-world rewiring loses some clustering", func(t *testing.T) {
+		_, ring := grow("small-world", TopologyOptions{Cells: 200, Degree: 6, Rewire: 0.0001})
+		_, rewired := grow("small-world", TopologyOptions{Cells: 200, Degree: 6, Rewire: 0.5})
+		assert.True(t, rewired.Clustering < ring.Clustering)
+	})
+	t.Run("scale-free grows hubs", func(t *testing.T) {
+		network, stats := grow("scale-free", TopologyOptions{Cells: 300, Degree: 2})
+		assert.Equal(t, 300, len(network.Cells))
+		maxDegree := 0
+		for _, cell := range network.Cells {
+			if degree := len(cell.AxonSynapses) + len(cell.DendriteSynapses); degree > maxDegree {
+				maxDegree = degree
+			}
+		}
+		assert.True(t, maxDegree > 5*int(stats.MeanDegree*2), maxDegree)
+	})
+	t.Run("lattice cells connect to their neighbors", func(t *testing.T) {
+		network, stats := grow("lattice", TopologyOptions{Cells: 9, Radius: 1})
+		assert.Equal(t, 9, stats.Cells)
+		// corners reach 3 cells, edges 5 and the middle 8
+		assert.Equal(t, 3, stats.MinOut)
+		assert.Equal(t, 8, stats.MaxOut)
+		assert.Equal(t, 8, len(network.GetCell(4).AxonSynapses))
+		assert.Equal(t, 4*3+4*5+8, stats.Synapses)
+	})
+	t.Run("layers only feed forward", func(t *testing.T) {
+		network, stats := grow("layered", TopologyOptions{Cells: 40, Layers: 4, Degree: 3})
+		assert.Equal(t, 40, stats.Cells)
+		assert.Equal(t, 30*3, stats.Synapses)
+		assert.Equal(t, 10, len(network.CellsInRegion("layer-3")))
+		for _, synapse := range network.Synapses {
+			assert.Equal(t, synapse.FromNeuronAxon/10+1, synapse.ToNeuronDendrite/10)
+		}
+	})
+	t.Run("the same seed grows the same network", func(t *testing.T) {
+		for _, name := range Topologies[1:] {
+			a, _ := grow(name, TopologyOptions{Cells: 30, Degree: 3})
+			b, _ := grow(name, TopologyOptions{Cells: 30, Degree: 3})
+			aJSON, _ := a.ToJSON()
+			bJSON, _ := b.ToJSON()
+			assert.Equal(t, aJSON, bJSON, name)
+		}
+	})
+	t.Run("unknown topologies are an error", func(t *testing.T) {
+		_, err := NewNetwork().GrowTopology("spaghetti", TopologyOptions{Cells: 10})
+		assert.Error(t, err)
+	})
+}
@@ -12,6 +12,7 @@ import (
 
 func copyVocabWithNewSamples(original *Vocabulary, samples []sample) *Vocabulary {
 	newVocab := NewVocabulary(CloneNetwork(original.Net))
+	newVocab.SetSeed(original.rng.Int63())
 	// copy Inputs and Outputs maps (maps pass by reference so new ones are needed)
 	for k, v := range original.Inputs {
 		newVocab.Inputs[k] = &VocabUnit{
@@ -27,15 +28,17 @@ func copyVocabWithNewSamples(original *Vocabulary, samples []sample) *Vocabulary
 	}
 	newVocab.Threads = original.Threads
 	newVocab.Workerfile = original.Workerfile
+	newVocab.SleepEvery = original.SleepEvery
+	newVocab.InputRegion = original.InputRegion
 	// this is the different one
 	newVocab.Samples = samples
 	return newVocab
 }
 
 // use the current time for easy reading, but also generate a random token
-func randFilename(prefix string, ext string) string {
+func randFilename(r *rand.Rand, prefix string, ext string) string {
 	now := strconv.FormatInt(time.Now().UTC().UnixNano(), 10)
-	return fmt.Sprintf("%s_%s_%d.%s", prefix, now, rand.Uint32(), ext)
+	return fmt.Sprintf("%s_%s_%d.%s", prefix, now, r.Uint32(), ext)
 }
 
 /*
@@ -172,8 +175,8 @@ func Train(masterVocab *Vocabulary, isRemoteWorkerWithTag string) {
 				}
 				defer w.conn.Close()
 				w.TranserExecutable()
-				tempVocabFile := randFilename("vocab", "json")
-				tempNetworkFile := randFilename("network", "nur")
+				tempVocabFile := randFilename(vocab.rng, "vocab", "json")
+				tempNetworkFile := randFilename(vocab.rng, "network", "nur")
 				err = vocab.SaveToFile(tempVocabFile)
 				if err != nil {
 					panic(err)
@@ -241,6 +244,10 @@ func Train(masterVocab *Vocabulary, isRemoteWorkerWithTag string) {
 				}
 				masterVocab.Net.PrintTotals()
 			}
+			if masterVocab.SleepEvery > 0 && merges%masterVocab.SleepEvery == 0 {
+				report := masterVocab.Net.Sleep(DefaultSleepOptions(masterVocab))
+				report.Print()
+			}
 			masterVocab.CheckAndReduceSimilarity()
 			chSendBackVocab <- copyVocabWithNewSamples(masterVocab, vocab.Samples)
 		case <-done:
@@ -0,0 +1,52 @@
+package potential
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TrainIsRepeatable(t *testing.T) {
+	// trains a fresh network on one thread, and returns the saved files
+	train := func(seed int64) (networkBytes, vocabBytes []byte) {
+		network := NewNetwork()
+		network.SetSeed(seed)
+		network.Grow(100, network.Laws.ComputedSynapsesPerCell(), 0)
+		vocab := NewVocabulary(network)
+		vocab.SetSeed(seed)
+		vocab.Threads = 1
+
+		units := []*UnitGroup{
+			{InputText: "1+3", ExpectedOutput: "4"},
+			{InputText: "2+2", ExpectedOutput: "4"},
+			{InputText: "1+1", ExpectedOutput: "2"},
+		}
+		data, err := json.Marshal(units)
+		assert.NoError(t, err)
+		err = vocab.AddTrainingData(data)
+		assert.NoError(t, err)
+
+		network.Disabled = true
+		Train(vocab, "")
+		vocab.ClearSamples()
+
+		err = network.SaveToFile("_train_repeat.test.nur")
+		assert.NoError(t, err)
+		err = vocab.SaveToFile("_train_repeat.test.json")
+		assert.NoError(t, err)
+		networkBytes, err = ioutil.ReadFile("_train_repeat.test.nur")
+		assert.NoError(t, err)
+		vocabBytes, err = ioutil.ReadFile("_train_repeat.test.json")
+		assert.NoError(t, err)
+		return networkBytes, vocabBytes
+	}
+
+	t.Run("single threaded training with the same seed has identical output files", func(t *testing.T) {
+		n1, v1 := train(7)
+		n2, v2 := train(7)
+		assert.Equal(t, n1, n2)
+		assert.Equal(t, v1, v2)
+	})
+}